@@ -0,0 +1,69 @@
+package shutdown
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// WithWorkerContext configures the parent of the contexts passed to the background tasks started with GoNamed, e.g. an
+// application context, so that cancelling it stops the tasks as well. The contexts are still cancelled when shutdown
+// begins. By default, the tasks are passed the context returned by ShutdownContext.
+func WithWorkerContext(ctx context.Context) Option {
+	return func(g *Shutdowner) {
+		g.cfg.workerCtx = ctx
+	}
+}
+
+// GoNamed runs fn in a new goroutine as a background task that Shutdown waits for, just like it waits for handlers. The
+// task is counted as active by ActiveCount and Stats, and in the remaining count of a drain that timed out. fn is
+// passed the context returned by ShutdownContext, or a context derived from the one configured with WithWorkerContext,
+// so it should return soon after that context is done. If tracking is enabled with WithTracking, the task is listed
+// under name by ActivePaths while it runs, which helps to identify the worker holding up a drain. Names should be easy
+// to tell apart from URL paths, e.g. "cache-refresher".
+func (g *Shutdowner) GoNamed(name string, fn func(ctx context.Context)) {
+	g.enter(nil)
+	var id uint64
+	if g.cfg.tracking {
+		id = g.track("", name)
+	}
+	go func() {
+		defer g.leave(nil)
+		if g.cfg.tracking {
+			defer g.untrack(id)
+		}
+		if g.cfg.workerCtx == nil {
+			fn(g.ShutdownContext())
+			return
+		}
+		ctx, cancel := g.shutdownContextFrom(g.cfg.workerCtx)
+		defer cancel()
+		fn(ctx)
+	}()
+}
+
+// Track counts a unit of work that is not an HTTP handler, e.g. a message being consumed, as active until the returned
+// function is called, so that Shutdown waits for it just like it waits for handlers. The work is included in
+// ActiveCount and Stats. Track is safe to call concurrently. Calling the returned function more than once is a misuse,
+// see WithStrictMode; the further calls have no effect otherwise. Calling it is the caller's responsibility: work that
+// is never marked done blocks Shutdown until its context is done.
+func (g *Shutdowner) Track() (done func()) {
+	g.enter(nil)
+	var finished atomic.Bool
+	return func() {
+		if !finished.CompareAndSwap(false, true) {
+			g.misuse("Track done called more than once")
+			return
+		}
+		g.leave(nil)
+	}
+}
+
+// Go runs f in a new goroutine whose work is counted by Track until f returns. Unlike GoNamed, f is not passed a
+// context; it should observe Done or ShutdownContext itself to return soon after shutdown begins.
+func (g *Shutdowner) Go(f func()) {
+	done := g.Track()
+	go func() {
+		defer done()
+		f()
+	}()
+}
@@ -0,0 +1,128 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_GoNamed(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithTracking())
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	shutdowner.GoNamed("cache-refresher", func(ctx context.Context) {
+		started <- struct{}{}
+		<-ctx.Done()
+	})
+	shutdowner.GoNamed("stuck-worker", func(ctx context.Context) {
+		started <- struct{}{}
+		<-release
+	})
+	<-started
+	<-started
+
+	if got, want := shutdowner.ActivePaths(), []string{"cache-refresher", "stuck-worker"}; !slices.Equal(got, want) {
+		t.Errorf("expected active tasks %v, got %v", want, got)
+	}
+	if got := shutdowner.ActiveCount(); got != 2 {
+		t.Errorf("expected the tasks to be counted as active, got %d", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var timeoutErr *shutdown.DrainTimeoutError
+	if err := shutdowner.Shutdown(ctx); !errors.As(err, &timeoutErr) || timeoutErr.Remaining != 1 {
+		t.Errorf("expected the drain to time out waiting for the stuck task, got %v", err)
+	}
+	for len(shutdowner.ActivePaths()) != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if got, want := shutdowner.ActivePaths(), []string{"stuck-worker"}; !slices.Equal(got, want) {
+		t.Errorf("expected only the stuck task to be active, got %v", got)
+	}
+
+	close(release)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+	if got := shutdowner.ActivePaths(); len(got) != 0 {
+		t.Errorf("expected no active tasks, got %v", got)
+	}
+}
+
+func TestWithWorkerContext(t *testing.T) {
+	t.Parallel()
+	appCtx, stopApp := context.WithCancel(context.Background())
+	shutdowner := shutdown.NewShutdowner(shutdown.WithWorkerContext(appCtx))
+
+	stopped := make(chan error, 2)
+	for range 2 {
+		shutdowner.GoNamed("worker", func(ctx context.Context) {
+			<-ctx.Done()
+			stopped <- context.Cause(ctx)
+		})
+	}
+	stopApp()
+	for range 2 {
+		if cause := <-stopped; !errors.Is(cause, context.Canceled) {
+			t.Errorf("expected the workers to stop with the application context, got %v", cause)
+		}
+	}
+	if shutdowner.IsShuttingDown() {
+		t.Errorf("expected the shutdown not to have begun")
+	}
+
+	// with a worker context, the workers still stop when shutdown begins
+	shutdowner = shutdown.NewShutdowner(shutdown.WithWorkerContext(context.Background()))
+	shutdowner.GoNamed("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		stopped <- context.Cause(ctx)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if cause := <-stopped; !errors.Is(cause, shutdown.ErrShuttingDown) {
+		t.Errorf("expected the worker to stop with %v, got %v", shutdown.ErrShuttingDown, cause)
+	}
+}
+
+func TestShutdowner_Track(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	done := shutdowner.Track()
+	release := make(chan struct{})
+	shutdowner.Go(func() { <-release })
+	if got := shutdowner.ActiveCount(); got != 2 {
+		t.Errorf("expected 2 active, got %d", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the shutdown to wait for the tracked work, got %v", err)
+	}
+
+	done()
+	done()
+	if got := shutdowner.ActiveCount(); got != 1 {
+		t.Errorf("expected 1 active after done was called twice, got %d", got)
+	}
+	close(release)
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+	if got := shutdowner.ActiveCount(); got != 0 {
+		t.Errorf("expected no active work, got %d", got)
+	}
+}
@@ -0,0 +1,70 @@
+package shutdown
+
+import "sync"
+
+// Barrier marks critical sections, possibly spanning several handlers, that must not be cut off by force-closing
+// connections. See NewBarrier.
+type Barrier struct {
+	g *Shutdowner
+
+	mu    sync.Mutex
+	n     int
+	empty chan struct{} // closed while the barrier is empty
+}
+
+// NewBarrier returns a new Barrier of the Shutdowner. When the drain times out and connections are force-closed
+// because of WithForceCloseOnTimeout, the force-close is delayed until all barriers of the Shutdowner are empty. Note
+// that this may keep Shutdown from returning past the deadline of its context for as long as a barrier is occupied.
+// Barriers are independent of the handlers tracked by Middleware: an occupied barrier does not delay the completion of
+// the drain.
+func (g *Shutdowner) NewBarrier() *Barrier {
+	b := &Barrier{g: g, empty: make(chan struct{})}
+	close(b.empty)
+	g.mu.Lock()
+	g.barriers = append(g.barriers, b)
+	g.mu.Unlock()
+	return b
+}
+
+// Enter marks the beginning of a critical section. Each call must be paired with a call to Leave.
+func (b *Barrier) Enter() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.n == 0 {
+		b.empty = make(chan struct{})
+	}
+	b.n++
+}
+
+// Leave marks the end of a critical section started with Enter. A call without a matching Enter is reported as misuse,
+// see WithStrictMode, and otherwise ignored.
+func (b *Barrier) Leave() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.n == 0 {
+		b.g.misuse("Barrier.Leave called without Enter")
+		return
+	}
+	b.n--
+	if b.n == 0 {
+		close(b.empty)
+	}
+}
+
+// wait waits until the barrier is empty.
+func (b *Barrier) wait() {
+	b.mu.Lock()
+	empty := b.empty
+	b.mu.Unlock()
+	<-empty
+}
+
+// waitBarriers waits until all barriers of the Shutdowner are empty.
+func (g *Shutdowner) waitBarriers() {
+	g.mu.Lock()
+	barriers := g.barriers
+	g.mu.Unlock()
+	for _, b := range barriers {
+		b.wait()
+	}
+}
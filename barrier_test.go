@@ -0,0 +1,68 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestBarrier_DoesNotDelayDrain(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithForceCloseOnTimeout())
+	barrier := shutdowner.NewBarrier()
+	barrier.Enter()
+	defer barrier.Leave()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Errorf("expected an occupied barrier not to delay the drain, got %v", err)
+	}
+}
+
+func TestBarrier_DelaysForceClose(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithForceCloseOnTimeout())
+	barrier := shutdowner.NewBarrier()
+
+	client, server := net.Pipe()
+	defer client.Close()
+	conn := shutdowner.TrackConn(server)
+	// keeps the drain from completing until the connection is closed
+	sessionDone := make(chan struct{})
+	shutdowner.GoNamed("session", func(ctx context.Context) {
+		defer close(sessionDone)
+		_, _ = conn.Read(make([]byte, 1))
+	})
+
+	barrier.Enter()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- shutdowner.Shutdown(ctx) }()
+
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("expected the force-close to wait for the barrier, but Shutdown returned %v", err)
+	default:
+	}
+	select {
+	case <-sessionDone:
+		t.Errorf("expected the connection to be open while the barrier is occupied")
+	default:
+	}
+
+	barrier.Leave()
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if report, _ := shutdowner.Report(); report.ForceClosed != 1 {
+		t.Errorf("expected 1 force-closed connection, got %d", report.ForceClosed)
+	}
+	<-sessionDone
+}
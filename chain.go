@@ -0,0 +1,23 @@
+package shutdown
+
+import "net/http"
+
+// Wrap is Middleware under the name middleware chains commonly use. Its method value has the popular
+// func(http.Handler) http.Handler signature, so it can be passed as is wherever such a middleware is expected, e.g.
+// alice.New(g.Wrap), as an alice.Constructor, or chi's router.Use(g.Wrap). negroni expects handlers calling the next
+// handler themselves instead, which is served by
+//
+//	negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+//		g.Wrap(next).ServeHTTP(w, r)
+//	})
+//
+// Like Middleware, Wrap should be the outermost middleware of the chain.
+func (g *Shutdowner) Wrap(next http.Handler) http.Handler {
+	return g.Middleware(next)
+}
+
+// MiddlewareFunc returns Middleware as a plain function value, e.g. for chi's router.Use(g.MiddlewareFunc()) or alice
+// chains.
+func (g *Shutdowner) MiddlewareFunc() func(http.Handler) http.Handler {
+	return g.Middleware
+}
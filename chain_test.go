@@ -0,0 +1,117 @@
+package shutdown_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+// constructor and chain are minimal stand-ins for the middleware chains of github.com/justinas/alice and
+// github.com/go-chi/chi, which share the func(http.Handler) http.Handler signature.
+type constructor func(http.Handler) http.Handler
+
+func chain(h http.Handler, constructors ...constructor) http.Handler {
+	for i := len(constructors) - 1; i >= 0; i-- {
+		h = constructors[i](h)
+	}
+	return h
+}
+
+// negroniHandlerFunc and negroniChain are minimal stand-ins for negroni.HandlerFunc and negroni.Negroni of
+// github.com/urfave/negroni.
+type negroniHandlerFunc func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
+
+func negroniChain(h http.Handler, handlers ...negroniHandlerFunc) http.Handler {
+	for i := len(handlers) - 1; i >= 0; i-- {
+		next, handler := h, handlers[i]
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { handler(w, r, next.ServeHTTP) })
+	}
+	return h
+}
+
+func TestShutdowner_Wrap(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name  string
+		build func(g *shutdown.Shutdowner, h http.Handler) http.Handler
+	}{
+		{
+			name: "alice",
+			build: func(g *shutdown.Shutdowner, h http.Handler) http.Handler {
+				return chain(h, g.Wrap, func(next http.Handler) http.Handler { return next })
+			},
+		},
+		{
+			name: "negroni",
+			build: func(g *shutdown.Shutdowner, h http.Handler) http.Handler {
+				return negroniChain(h, func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+					g.Wrap(next).ServeHTTP(w, r)
+				})
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var shutdowner shutdown.Shutdowner
+			entered, release := make(chan struct{}), make(chan struct{})
+			handler := tc.build(&shutdowner, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(entered)
+				<-release
+			}))
+			go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ws", nil))
+			<-entered
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+			defer cancel()
+			if err := shutdowner.Shutdown(ctx); err == nil {
+				t.Fatalf("expected the shutdown to wait for the handler")
+			}
+			close(release)
+			if err := shutdowner.Shutdown(context.Background()); err != nil {
+				t.Errorf("no error expected but got %v", err)
+			}
+		})
+	}
+}
+
+// router is a minimal stand-in for the router of github.com/go-chi/chi and its Use method.
+type router struct {
+	middlewares []func(http.Handler) http.Handler
+}
+
+func (rt *router) Use(middlewares ...func(http.Handler) http.Handler) {
+	rt.middlewares = append(rt.middlewares, middlewares...)
+}
+
+func (rt *router) Handler(h http.Handler) http.Handler {
+	for i := len(rt.middlewares) - 1; i >= 0; i-- {
+		h = rt.middlewares[i](h)
+	}
+	return h
+}
+
+func TestShutdowner_MiddlewareFunc(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	var rt router
+	rt.Use(shutdowner.MiddlewareFunc())
+	var active int64
+	handler := rt.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		active = shutdowner.ActiveCount()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if active != 1 {
+		t.Errorf("expected the handler to be tracked, got %d active", active)
+	}
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package shutdown
+
+import (
+	"context"
+	"time"
+)
+
+// CloseOnShutdown closes a websocket connection gracefully when shutdown begins, without depending on a websocket
+// package. It waits until done, typically the channel returned by Done, is closed, then calls sendClose to send a
+// close frame, e.g. with code 1001 "going away", and waits for the peer to acknowledge it. ctx must be done once the
+// connection is closed, i.e. the caller cancels it when its read loop returns because the peer answered the close
+// frame or the connection failed. The wait for the acknowledgement is bounded by timeout, so that a dead peer does not
+// block the drain; ErrCloseNotAcknowledged is returned if it is exceeded. If ctx is done before shutdown began,
+// CloseOnShutdown returns nil without sending a close frame. The error of sendClose is returned as is.
+func CloseOnShutdown(ctx context.Context, done <-chan struct{}, timeout time.Duration, sendClose func() error) error {
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-done:
+	}
+	if err := sendClose(); err != nil {
+		return err
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-t.C:
+		return ErrCloseNotAcknowledged
+	}
+}
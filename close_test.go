@@ -0,0 +1,113 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+// wsConn is the subset of the methods of *websocket.Conn of github.com/gorilla/websocket used by the example.
+type wsConn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+}
+
+func ExampleCloseOnShutdown() {
+	var shutdowner shutdown.Shutdowner
+	var upgrade func(w http.ResponseWriter, r *http.Request) (wsConn, error) // e.g. websocket.Upgrader.Upgrade
+
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrade(w, r)
+		if err != nil {
+			return
+		}
+		ctx, cancel := context.WithCancel(r.Context())
+		closed := make(chan error, 1)
+		go func() {
+			closed <- shutdown.CloseOnShutdown(ctx, shutdowner.Done(), 5*time.Second, func() error {
+				// websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down")
+				msg := append([]byte{0x03, 0xe9}, "shutting down"...)
+				// websocket.CloseMessage
+				return conn.WriteControl(8, msg, time.Now().Add(time.Second))
+			})
+		}()
+
+		// the read loop fails once the peer answered the close frame
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				break
+			}
+		}
+		cancel()
+		if err := <-closed; err != nil {
+			log.Printf("graceful close failed: %v", err)
+		}
+	}))
+	_ = handler
+}
+
+func TestCloseOnShutdown(t *testing.T) {
+	t.Parallel()
+
+	t.Run("acknowledged", func(t *testing.T) {
+		t.Parallel()
+		var shutdowner shutdown.Shutdowner
+		ctx, connClosed := context.WithCancel(context.Background())
+		var sent bool
+		errs := make(chan error, 1)
+		go func() {
+			errs <- shutdown.CloseOnShutdown(ctx, shutdowner.Done(), time.Second, func() error {
+				sent = true
+				// the peer answers the close frame
+				go connClosed()
+				return nil
+			})
+		}()
+		_ = shutdowner.BeginShutdown()
+		if err := <-errs; err != nil {
+			t.Errorf("no error expected but got %v", err)
+		}
+		if !sent {
+			t.Errorf("expected a close frame to be sent")
+		}
+	})
+
+	t.Run("not acknowledged", func(t *testing.T) {
+		t.Parallel()
+		done := make(chan struct{})
+		close(done)
+		err := shutdown.CloseOnShutdown(context.Background(), done, 5*time.Millisecond, func() error { return nil })
+		if !errors.Is(err, shutdown.ErrCloseNotAcknowledged) {
+			t.Errorf("expected %v, got %v", shutdown.ErrCloseNotAcknowledged, err)
+		}
+	})
+
+	t.Run("send fails", func(t *testing.T) {
+		t.Parallel()
+		done := make(chan struct{})
+		close(done)
+		errBroken := errors.New("broken pipe")
+		err := shutdown.CloseOnShutdown(context.Background(), done, time.Second, func() error { return errBroken })
+		if !errors.Is(err, errBroken) {
+			t.Errorf("expected %v, got %v", errBroken, err)
+		}
+	})
+
+	t.Run("connection closed before shutdown", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := shutdown.CloseOnShutdown(ctx, make(chan struct{}), time.Second, func() error {
+			t.Errorf("expected no close frame to be sent")
+			return nil
+		})
+		if err != nil {
+			t.Errorf("no error expected but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+)
+
+// trackedConn is a net.Conn returned by TrackConn. It removes itself from the tracked connections when closed.
+type trackedConn struct {
+	net.Conn
+	g    *Shutdowner
+	id   uint64
+	path string // the request path if obtained by hijacking with WithHijackTracking
+	once sync.Once
+}
+
+// Close closes the underlying connection and stops tracking it.
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.g.connMu.Lock()
+		delete(c.g.conns, c.id)
+		c.g.connMu.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+// keepAliveSetter is implemented by connections whose TCP keep-alive can be configured, such as *net.TCPConn.
+type keepAliveSetter interface {
+	SetKeepAlive(keepalive bool) error
+}
+
+// TrackConn returns a wrapper of conn that the Shutdowner keeps track of until it is closed, e.g. for a connection
+// obtained by hijacking. All net.Conn methods are forwarded to conn.
+func (g *Shutdowner) TrackConn(conn net.Conn) net.Conn {
+	return g.trackConn(conn, "")
+}
+
+// trackConn tracks conn, which belongs to a request for path, if known.
+func (g *Shutdowner) trackConn(conn net.Conn, path string) net.Conn {
+	c := &trackedConn{Conn: conn, g: g, id: g.nextID.Add(1), path: path}
+	g.connMu.Lock()
+	if g.conns == nil {
+		g.conns = make(map[uint64]*trackedConn)
+	}
+	g.conns[c.id] = c
+	g.connMu.Unlock()
+
+	if g.cfg.disableKeepAlive && g.shuttingDown.Load() {
+		g.disableKeepAlive(c)
+	}
+	return c
+}
+
+// WithDisableKeepAliveOnShutdown configures the Shutdowner to disable TCP keep-alive on the connections tracked with
+// TrackConn when shutdown begins, so that the operating system does not keep half-dead connections alive past the
+// drain. Connections that don't support keep-alive, i.e. anything but *net.TCPConn or connections with a
+// SetKeepAlive(bool) error method, are skipped.
+func WithDisableKeepAliveOnShutdown() Option {
+	return func(g *Shutdowner) {
+		g.cfg.disableKeepAlive = true
+	}
+}
+
+// trackedConns returns a snapshot of the tracked connections.
+func (g *Shutdowner) trackedConns() []*trackedConn {
+	g.connMu.Lock()
+	defer g.connMu.Unlock()
+	conns := make([]*trackedConn, 0, len(g.conns))
+	for _, c := range g.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// WithForceCloseOnTimeout configures Shutdown to close the connections tracked with TrackConn when its context is done
+// before the drain completed, so that handlers stuck on a hijacked connection are cut off. The closed connections are
+// counted in the Report.
+func WithForceCloseOnTimeout() Option {
+	return func(g *Shutdowner) {
+		g.cfg.forceCloseOnTimeout = true
+	}
+}
+
+// WithWaitForTrackedConns configures Shutdown to also wait for the connections tracked with TrackConn to be closed, so
+// that connections which outlive their handler, e.g. because they were handed to a background goroutine, don't evade
+// the drain. Combined with WithForceCloseOnTimeout, the connections still open when the context passed to Shutdown is
+// done are closed. The connections are checked at the interval configured with WithPollInterval.
+func WithWaitForTrackedConns() Option {
+	return func(g *Shutdowner) {
+		g.cfg.waitForConns = true
+	}
+}
+
+// waitConns waits until all tracked connections are closed, if configured.
+func (g *Shutdowner) waitConns(ctx context.Context, abandoned <-chan struct{}) error {
+	if !g.cfg.waitForConns {
+		return nil
+	}
+	return g.poll(ctx, abandoned, func() bool {
+		g.connMu.Lock()
+		defer g.connMu.Unlock()
+		return len(g.conns) == 0
+	})
+}
+
+// WithPreserveBelow configures the force-close of WithForceCloseOnTimeout to only close the tracked connections if at
+// least n of them are open when the drain times out. Fewer connections are preserved, i.e. left open for the
+// application to deal with, e.g. for canary instances whose few long-lived connections are worth keeping.
+func WithPreserveBelow(n int64) Option {
+	return func(g *Shutdowner) {
+		g.cfg.preserveBelow = n
+	}
+}
+
+// forceClose closes the tracked connections after the drain timed out, unless fewer than configured with
+// WithPreserveBelow are open, and returns the paths of the closed connections.
+func (g *Shutdowner) forceClose() []string {
+	if g.cfg.preserveBelow > 0 {
+		g.connMu.Lock()
+		open := int64(len(g.conns))
+		g.connMu.Unlock()
+		if open < g.cfg.preserveBelow {
+			g.log(slog.LevelInfo, "preserving connections", slog.Int64("open", open))
+			return nil
+		}
+	}
+	return g.closeTrackedConns()
+}
+
+// closeTrackedConns closes all tracked connections and returns their paths, which are empty if unknown.
+func (g *Shutdowner) closeTrackedConns() []string {
+	conns := g.trackedConns()
+	paths := make([]string, 0, len(conns))
+	for _, c := range conns {
+		if err := c.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			g.log(slog.LevelWarn, "failed to close connection", slog.Any("error", err))
+		}
+		paths = append(paths, c.path)
+	}
+	return paths
+}
+
+// disableKeepAlives disables keep-alive on all tracked connections if configured.
+func (g *Shutdowner) disableKeepAlives() {
+	if !g.cfg.disableKeepAlive {
+		return
+	}
+	for _, c := range g.trackedConns() {
+		g.disableKeepAlive(c)
+	}
+}
+
+// disableKeepAlive disables keep-alive on a single connection, if supported.
+func (g *Shutdowner) disableKeepAlive(c *trackedConn) {
+	s, ok := c.Conn.(keepAliveSetter)
+	if !ok {
+		return
+	}
+	if err := s.SetKeepAlive(false); err != nil {
+		g.log(slog.LevelWarn, "failed to disable keep-alive", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,174 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+// keepAliveConn records calls to SetKeepAlive like a *net.TCPConn would receive them.
+type keepAliveConn struct {
+	net.Conn
+	disabled atomic.Int32
+}
+
+func (c *keepAliveConn) SetKeepAlive(keepalive bool) error {
+	if !keepalive {
+		c.disabled.Add(1)
+	}
+	return nil
+}
+
+func newKeepAliveConn(t *testing.T) *keepAliveConn {
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return &keepAliveConn{Conn: server}
+}
+
+func TestWithDisableKeepAliveOnShutdown(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithDisableKeepAliveOnShutdown())
+
+	open, closed := newKeepAliveConn(t), newKeepAliveConn(t)
+	_ = shutdowner.TrackConn(open)
+	_ = shutdowner.TrackConn(closed).Close()
+	plain, _ := net.Pipe()
+	defer plain.Close()
+	_ = shutdowner.TrackConn(plain) // no keep-alive support, skipped
+
+	_ = shutdowner.BeginShutdown()
+	if got := open.disabled.Load(); got != 1 {
+		t.Errorf("expected keep-alive of the open connection to be disabled once, got %d", got)
+	}
+	if got := closed.disabled.Load(); got != 0 {
+		t.Errorf("expected the closed connection not to be touched, got %d calls", got)
+	}
+
+	// connections tracked after shutdown began are disabled right away
+	late := newKeepAliveConn(t)
+	_ = shutdowner.TrackConn(late)
+	if got := late.disabled.Load(); got != 1 {
+		t.Errorf("expected keep-alive of the late connection to be disabled, got %d", got)
+	}
+}
+
+func TestWithDisableKeepAliveOnShutdown_TCP(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		if c, err := l.Accept(); err == nil {
+			defer c.Close()
+			_, _ = c.Read(make([]byte, 1))
+		}
+	}()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+
+	shutdowner := shutdown.NewShutdowner(shutdown.WithDisableKeepAliveOnShutdown())
+	tracked := shutdowner.TrackConn(conn)
+	_ = shutdowner.BeginShutdown()
+
+	// the tracked connection keeps working after keep-alive has been disabled
+	if _, err := tracked.Write([]byte("x")); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+	if err := tracked.Close(); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+}
+
+func TestWithPreserveBelow(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name            string
+		preserveBelow   int64
+		wantForceClosed int
+	}{
+		{name: "below threshold", preserveBelow: 3, wantForceClosed: 0},
+		{name: "at threshold", preserveBelow: 2, wantForceClosed: 2},
+		{name: "disabled", wantForceClosed: 2},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			shutdowner := shutdown.NewShutdowner(
+				shutdown.WithForceCloseOnTimeout(),
+				shutdown.WithPreserveBelow(tc.preserveBelow),
+			)
+			var conns []net.Conn
+			for range 2 {
+				conns = append(conns, shutdowner.TrackConn(newKeepAliveConn(t)))
+			}
+			release := make(chan struct{})
+			defer close(release)
+			shutdowner.GoNamed("stuck", func(ctx context.Context) { <-release })
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			_ = shutdowner.Shutdown(ctx)
+
+			if report, _ := shutdowner.Report(); report.ForceClosed != tc.wantForceClosed {
+				t.Errorf("expected %d force-closed connections, got %d", tc.wantForceClosed, report.ForceClosed)
+			}
+			for _, c := range conns {
+				err := c.SetDeadline(time.Now().Add(time.Second))
+				if preserved := err == nil; preserved != (tc.wantForceClosed == 0) {
+					t.Errorf("unexpected state of the connection, SetDeadline returned %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithWaitForTrackedConns(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithWaitForTrackedConns(), shutdown.WithForceCloseOnTimeout())
+
+	// the connections are handed to background goroutines, so no handler keeps the drain going
+	client, server := net.Pipe()
+	defer client.Close()
+	stuck := shutdowner.TrackConn(server)
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := stuck.Read(make([]byte, 1))
+		readErr <- err
+	}()
+	client2, server2 := net.Pipe()
+	defer client2.Close()
+	closing := shutdowner.TrackConn(server2)
+	go func() {
+		<-shutdowner.Done()
+		_ = closing.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the drain to wait for the open connection, got %v", err)
+	}
+	if err := <-readErr; !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("expected the open connection to be force-closed, got %v", err)
+	}
+	if report, _ := shutdowner.Report(); report.ForceClosed != 1 {
+		t.Errorf("expected 1 force-closed connection, got %d", report.ForceClosed)
+	}
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Errorf("expected the drain to complete once the connections are closed, got %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package shutdown
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// contextKey is the key under which Middleware stores the Shutdowner in the request context.
+type contextKey struct{}
+
+// WithContextInjection configures the middleware to store the Shutdowner in the request context of the wrapped
+// handlers, which is required for RemainingGrace and FromContext. The injected context is also cancelled by
+// ShutdownPhased once its soft phase expired. Injecting the context costs a few allocations per request, so it is
+// disabled by default.
+func WithContextInjection() Option {
+	return func(g *Shutdowner) {
+		g.cfg.contextInjection = true
+	}
+}
+
+// FromContext returns the Shutdowner that stored itself in ctx, so that code deep in the call chain of a handler can
+// check IsShuttingDown or wait for Done without the Shutdowner being passed down. It is only present in the context of
+// a request served by a handler wrapped with Middleware of a Shutdowner configured with WithContextInjection or
+// WithContextCancellation, and in contexts derived from it; the boolean result is false otherwise.
+func FromContext(ctx context.Context) (*Shutdowner, bool) {
+	g, ok := ctx.Value(contextKey{}).(*Shutdowner)
+	return g, ok
+}
+
+// RemainingGrace returns how much time is left before the deadline of the context passed to Shutdown. It must be called
+// with the context of a request served by a handler wrapped with Middleware of a Shutdowner configured with
+// WithContextInjection or WithContextCancellation, or with a context derived from it. The boolean result is false if
+// ctx does not belong to such a request or if shutdown has not begun yet. If shutdown has begun with a context that has
+// no deadline, the maximum time.Duration is returned. Handlers can use it to decide whether to start a new expensive
+// operation.
+func RemainingGrace(ctx context.Context) (time.Duration, bool) {
+	g, ok := ctx.Value(contextKey{}).(*Shutdowner)
+	if !ok || !g.shuttingDown.Load() {
+		return 0, false
+	}
+
+	g.mu.Lock()
+	deadline := g.deadline
+	g.mu.Unlock()
+
+	if deadline.IsZero() {
+		return math.MaxInt64, true
+	}
+	return max(time.Until(deadline), 0), true
+}
+
+// WithContextCancellation configures the middleware to cancel the request context of the wrapped handlers when
+// shutdown begins, so that r.Context().Done() fires for active handlers at drain time. It implies
+// WithContextInjection. The original request context is the parent of the injected context, so client disconnects
+// still cancel it as well. After shutdown began, context.Cause reports ErrShuttingDown for the injected context. As long
+// as a handler does not call Done or Err, the injected context costs two allocations per request; setting up its
+// cancellation at shutdown is deferred until then.
+//
+// Handlers are still responsible for actually returning once the context is done, since Shutdown keeps waiting for
+// them.
+func WithContextCancellation() Option {
+	return func(g *Shutdowner) {
+		g.cfg.contextCancellation = true
+	}
+}
+
+// ShutdownContext returns a long-lived context that is cancelled when shutdown begins, so that background work like
+// caches or refreshers can tie its lifetime to the shutdown without going through a handler. It is the non-request
+// counterpart of the context injected with WithContextCancellation, and context.Cause reports the same cause for it.
+// Repeated calls return the same context.
+func (g *Shutdowner) ShutdownContext() context.Context {
+	return g.baseContext()
+}
+
+// Done returns a channel that is closed when shutdown begins, e.g. so that the ping loop of a websocket handler can
+// select on it besides the request context. It is the Done channel of the context returned by ShutdownContext, so
+// repeated calls return the same channel.
+func (g *Shutdowner) Done() <-chan struct{} {
+	return g.baseContext().Done()
+}
+
+// SubContext returns a context derived from parent that is also cancelled when shutdown begins, with the same cause as
+// the context returned by ShutdownContext, and registers the sub-operation using it so that Shutdown waits for it, just
+// like it waits for handlers. The sub-operation is counted as active by ActiveCount and Stats until it is released. The
+// returned function must be called once the sub-operation is done: it cancels the context and releases the
+// registration. Calling it more than once is a misuse, see WithStrictMode; the further calls have no effect otherwise.
+func (g *Shutdowner) SubContext(parent context.Context) (context.Context, func()) {
+	g.enter(nil)
+	ctx, cancel := g.shutdownContextFrom(parent)
+	var released atomic.Bool
+	return ctx, func() {
+		if !released.CompareAndSwap(false, true) {
+			g.misuse("SubContext release called more than once")
+			return
+		}
+		cancel()
+		g.leave(nil)
+	}
+}
+
+// shutdownContextFrom returns a context derived from parent that is also cancelled when shutdown begins, with the same
+// cause as the context returned by ShutdownContext, and a function releasing its resources.
+func (g *Shutdowner) shutdownContextFrom(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	base := g.baseContext()
+	stop := context.AfterFunc(base, func() { cancel(context.Cause(base)) })
+	if base.Err() != nil {
+		cancel(context.Cause(base))
+	}
+	return ctx, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
+
+// baseState holds the long-lived context that is cancelled with ErrShuttingDown when shutdown begins.
+type baseState struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+// baseContext returns the long-lived context that is cancelled with ErrShuttingDown when shutdown begins. Once
+// initialized, it is read without locking, since it is used on the hot path of drainContext.
+func (g *Shutdowner) baseContext() context.Context {
+	if b := g.base.Load(); b != nil {
+		return b.ctx
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.baseStateLocked().ctx
+}
+
+// baseStateLocked lazily initializes the base context. g.mu must be held.
+func (g *Shutdowner) baseStateLocked() *baseState {
+	if b := g.base.Load(); b != nil {
+		return b
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	b := &baseState{ctx: ctx, cancel: cancel}
+	g.base.Store(b)
+	return b
+}
+
+// drainContext is the request context injected by the middleware when WithContextCancellation is enabled. It doubles as
+// the context carrying the Shutdowner, and the derived context that is cancelled at shutdown is only set up on the first
+// call to Done or Err, so handlers that never look at the context don't pay for it.
+type drainContext struct {
+	context.Context // the original request context
+	g               *Shutdowner
+
+	state atomic.Pointer[drainState]
+
+	mu       sync.Mutex
+	released bool
+}
+
+// drainState is the lazily created part of a drainContext.
+type drainState struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	stop   func() bool
+}
+
+// get returns the derived context, creating it if necessary.
+func (c *drainContext) get() context.Context {
+	if s := c.state.Load(); s != nil {
+		return s.ctx
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s := c.state.Load(); s != nil {
+		return s.ctx
+	}
+	ctx, cancel := context.WithCancelCause(c.Context)
+	base := c.g.baseContext()
+	s := &drainState{
+		ctx:    ctx,
+		cancel: cancel,
+		stop:   context.AfterFunc(base, func() { cancel(context.Cause(base)) }),
+	}
+	if base.Err() != nil {
+		// AfterFunc calls the function in its own goroutine if base is already done, so the cancellation must not
+		// be left to it or requests arriving after shutdown began would briefly see a live context.
+		cancel(context.Cause(base))
+	}
+	if c.released {
+		s.stop()
+		cancel(context.Canceled)
+	}
+	c.state.Store(s)
+	return ctx
+}
+
+// release frees the resources of the derived context, if any. It is called when the handler returns.
+func (c *drainContext) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.released = true
+	if s := c.state.Load(); s != nil {
+		s.stop()
+		s.cancel(context.Canceled)
+	}
+}
+
+func (c *drainContext) Done() <-chan struct{} {
+	return c.get().Done()
+}
+
+func (c *drainContext) Err() error {
+	return c.get().Err()
+}
+
+// Value returns the Shutdowner for the middleware's context key. Other keys are looked up in the derived context once
+// it exists, so that context.Cause reports the cause of the shutdown. Once shutdown began, the derived context is
+// created for any lookup, since context.Cause finds it by means of such a lookup with an unexported key.
+func (c *drainContext) Value(key any) any {
+	if key == (contextKey{}) {
+		return c.g
+	}
+	if s := c.state.Load(); s != nil {
+		return s.ctx.Value(key)
+	}
+	if c.g.shuttingDown.Load() {
+		return c.get().Value(key)
+	}
+	return c.Context.Value(key)
+}
@@ -0,0 +1,405 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestRemainingGrace(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithContextInjection())
+
+	if _, ok := shutdown.RemainingGrace(context.Background()); ok {
+		t.Errorf("expected no remaining grace outside of a handler")
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	type grace struct {
+		remaining time.Duration
+		ok        bool
+	}
+	results := make(chan grace)
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := shutdown.RemainingGrace(r.Context()); ok {
+			t.Errorf("expected no remaining grace before shutdown")
+		}
+		close(started)
+		for range release {
+			remaining, ok := shutdown.RemainingGrace(r.Context())
+			results <- grace{remaining, ok}
+		}
+	}))
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- shutdowner.Shutdown(ctx) }()
+
+	// wait for shutdown to begin
+	for {
+		time.Sleep(time.Millisecond)
+		release <- struct{}{}
+		if g := <-results; g.ok {
+			break
+		}
+	}
+
+	release <- struct{}{}
+	first := <-results
+	time.Sleep(10 * time.Millisecond)
+	release <- struct{}{}
+	second := <-results
+	close(release)
+
+	if !first.ok || !second.ok {
+		t.Errorf("expected remaining grace after shutdown began")
+	}
+	if first.remaining > time.Second || first.remaining <= 0 {
+		t.Errorf("expected remaining grace in (0, 1s], got %v", first.remaining)
+	}
+	if second.remaining >= first.remaining {
+		t.Errorf("expected remaining grace to decrease, got %v then %v", first.remaining, second.remaining)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+}
+
+func TestRemainingGrace_WithoutContextInjection(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	var ok bool
+	shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok = shutdown.RemainingGrace(r.Context())
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if ok {
+		t.Errorf("expected no remaining grace without context injection")
+	}
+}
+
+func TestRemainingGrace_WithContextCancellation(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithContextCancellation())
+
+	started := make(chan struct{})
+	type grace struct {
+		remaining time.Duration
+		ok        bool
+	}
+	graces := make(chan grace, 1)
+	go shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		remaining, ok := shutdown.RemainingGrace(r.Context())
+		graces <- grace{remaining, ok}
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if g := <-graces; !g.ok || g.remaining <= 0 || g.remaining > time.Minute {
+		t.Errorf("expected a remaining grace of up to a minute, got %v, %t", g.remaining, g.ok)
+	}
+}
+
+func TestWithContextCancellation(t *testing.T) {
+	t.Parallel()
+
+	t.Run("cancelled when shutdown begins", func(t *testing.T) {
+		t.Parallel()
+		shutdowner := shutdown.NewShutdowner(shutdown.WithContextCancellation())
+
+		started := make(chan struct{})
+		causes := make(chan error, 1)
+		go shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-r.Context().Done()
+			causes <- context.Cause(r.Context())
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := shutdowner.Shutdown(ctx); err != nil {
+			t.Fatalf("no error expected but got %v", err)
+		}
+		if cause := <-causes; !errors.Is(cause, shutdown.ErrShuttingDown) {
+			t.Errorf("expected cause %v, got %v", shutdown.ErrShuttingDown, cause)
+		}
+	})
+
+	t.Run("request cancellation still propagates", func(t *testing.T) {
+		t.Parallel()
+		shutdowner := shutdown.NewShutdowner(shutdown.WithContextCancellation())
+
+		reqCtx, cancelReq := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+		go shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			errs <- r.Context().Err()
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(reqCtx))
+
+		cancelReq()
+		select {
+		case err := <-errs:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected %v, got %v", context.Canceled, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("request cancellation did not propagate")
+		}
+		if shutdowner.IsDrained() {
+			t.Errorf("expected shutdown not to have begun")
+		}
+	})
+
+	t.Run("request arriving after shutdown began", func(t *testing.T) {
+		t.Parallel()
+		shutdowner := shutdown.NewShutdowner(shutdown.WithContextCancellation())
+		if err := shutdowner.Shutdown(context.Background()); err != nil {
+			t.Fatalf("no error expected but got %v", err)
+		}
+
+		var err error
+		shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			err = r.Context().Err()
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected %v, got %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("context values are preserved", func(t *testing.T) {
+		t.Parallel()
+		shutdowner := shutdown.NewShutdowner(shutdown.WithContextCancellation())
+
+		type key struct{}
+		r := httptest.NewRequest("GET", "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), key{}, "value"))
+		var before, after any
+		shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			before = r.Context().Value(key{})
+			_ = r.Context().Done() // forces the lazily derived context
+			after = r.Context().Value(key{})
+		})).ServeHTTP(httptest.NewRecorder(), r)
+		if before != "value" || after != "value" {
+			t.Errorf("expected context value to be preserved, got %v and %v", before, after)
+		}
+	})
+}
+
+func TestWithContextCancellation_CauseWithoutDone(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithContextCancellation())
+
+	reqCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	started, release := make(chan struct{}), make(chan struct{})
+	causes := make(chan error, 1)
+	go shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		causes <- context.Cause(r.Context())
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(reqCtx))
+	<-started
+
+	// begin shutdown without the handler touching its context
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancelShutdown()
+	if err := shutdowner.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, but got %v", context.DeadlineExceeded, err)
+	}
+	close(release)
+
+	if cause := <-causes; !errors.Is(cause, shutdown.ErrShuttingDown) {
+		t.Errorf("expected cause %v, got %v", shutdown.ErrShuttingDown, cause)
+	}
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+}
+
+func TestShutdowner_ShutdownContext(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	ctx := shutdowner.ShutdownContext()
+	if ctx != shutdowner.ShutdownContext() {
+		t.Errorf("expected the same context to be returned on every call")
+	}
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("expected the context not to be cancelled before shutdown, got %v", err)
+	}
+
+	_ = shutdowner.BeginShutdown()
+	select {
+	case <-ctx.Done():
+	default:
+		t.Fatalf("expected the context to be cancelled when shutdown begins")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, shutdown.ErrShuttingDown) {
+		t.Errorf("expected cause %v, got %v", shutdown.ErrShuttingDown, cause)
+	}
+	if ctx != shutdowner.ShutdownContext() {
+		t.Errorf("expected the same context to be returned after shutdown began")
+	}
+}
+
+func TestShutdowner_Done(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	// the zero value initializes the channel lazily, also under concurrent calls
+	channels := make(chan (<-chan struct{}), 10)
+	for range cap(channels) {
+		go func() { channels <- shutdowner.Done() }()
+	}
+	done := shutdowner.Done()
+	for range cap(channels) {
+		if <-channels != done {
+			t.Fatalf("expected the same channel to be returned on every call")
+		}
+	}
+	select {
+	case <-done:
+		t.Fatalf("expected the channel not to be closed before shutdown")
+	default:
+	}
+
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	select {
+	case <-done:
+	default:
+		t.Fatalf("expected the channel to be closed when shutdown begins")
+	}
+	if !shutdowner.IsShuttingDown() {
+		t.Errorf("expected shutdown to have begun")
+	}
+}
+
+func TestShutdowner_SubContext(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	ctx, release := shutdowner.SubContext(context.Background())
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("expected a live context before shutdown, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		drainCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		done <- shutdowner.Shutdown(drainCtx)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("expected the sub context to be cancelled when shutdown begins")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, shutdown.ErrShuttingDown) {
+		t.Errorf("expected cause %v, got %v", shutdown.ErrShuttingDown, cause)
+	}
+
+	select {
+	case err := <-done:
+		t.Fatalf("expected the drain to wait for the sub context to be released, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	release()
+	release()
+	if err := <-done; err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+}
+
+func TestShutdowner_SubContext_ParentCancelled(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	parent, cancel := context.WithCancel(context.Background())
+	ctx, release := shutdowner.SubContext(parent)
+	defer release()
+	cancel()
+	<-ctx.Done()
+	if shutdowner.IsShuttingDown() {
+		t.Errorf("cancelling the parent must not begin shutdown")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name   string
+		opts   []shutdown.Option
+		wantOK bool
+	}{
+		{name: "context injection", opts: []shutdown.Option{shutdown.WithContextInjection()}, wantOK: true},
+		{name: "context cancellation", opts: []shutdown.Option{shutdown.WithContextCancellation()}, wantOK: true},
+		{name: "not injected"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			shutdowner := shutdown.NewShutdowner(tc.opts...)
+			var got *shutdown.Shutdowner
+			var ok bool
+			shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// e.g. deep in library code
+				ctx, cancel := context.WithCancel(r.Context())
+				defer cancel()
+				got, ok = shutdown.FromContext(ctx)
+			})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			if ok != tc.wantOK || (ok && got != shutdowner) {
+				t.Errorf("expected the shutdowner to be found: %t, got %p, %t", tc.wantOK, got, ok)
+			}
+		})
+	}
+
+	if _, ok := shutdown.FromContext(context.Background()); ok {
+		t.Errorf("expected no shutdowner outside of a request")
+	}
+}
+
+func TestShutdowner_SubContext_CountedAsActive(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	_, release := shutdowner.SubContext(context.Background())
+	if got := shutdowner.ActiveCount(); got != 1 {
+		t.Errorf("expected the sub-operation to be counted as active, got %d", got)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	var timeoutErr *shutdown.DrainTimeoutError
+	if err := shutdowner.Shutdown(ctx); !errors.As(err, &timeoutErr) || timeoutErr.Remaining != 1 {
+		t.Errorf("expected the drain to time out with the sub-operation remaining, got %v", err)
+	}
+
+	release()
+	if got := shutdowner.ActiveCount(); got != 0 {
+		t.Errorf("expected no active sub-operations after the release, got %d", got)
+	}
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrAborted is returned by Shutdown when the drain has been abandoned with AbandonDrain.
+	ErrAborted = errors.New("shutdown: drain abandoned")
+	// ErrShuttingDown is the cause of the cancellation of contexts that are cancelled when shutdown begins.
+	ErrShuttingDown = errors.New("shutdown: shutting down")
+	// ErrGraceExpired is the cause of the cancellation of request contexts whose grace configured with WithHandlerGrace
+	// has elapsed, or that are cancelled by ShutdownPhased, ShutdownWithDeadlines or ShutdownRateLimited.
+	ErrGraceExpired = errors.New("shutdown: handler grace expired")
+	// ErrMaxHandlerDurationExceeded is the cause of the cancellation of request contexts whose handler ran for longer
+	// than configured with WithMaxHandlerDuration.
+	ErrMaxHandlerDurationExceeded = errors.New("shutdown: maximum handler duration exceeded")
+	// ErrForcedShutdown is returned by RunUntilSignalWithForce when a second signal forced the shutdown.
+	ErrForcedShutdown = errors.New("shutdown: forced by second signal")
+	// ErrSpawnLimitExceeded is returned by SpawnTracked when the request has spawned as many goroutines as configured
+	// with WithMaxSpawnPerRequest.
+	ErrSpawnLimitExceeded = errors.New("shutdown: spawn limit per request exceeded")
+	// ErrCloseNotAcknowledged is returned by CloseOnShutdown when the peer did not acknowledge the close frame in time.
+	ErrCloseNotAcknowledged = errors.New("shutdown: close frame not acknowledged")
+)
+
+// DrainTimeoutError is returned by Shutdown when its context reached its deadline before the drain completed. It wraps
+// the context error, so errors.Is(err, context.DeadlineExceeded) holds.
+type DrainTimeoutError struct {
+	// Remaining is the number of handlers that were still active.
+	Remaining int64
+	// Err is the error of the context.
+	Err error
+}
+
+func (e *DrainTimeoutError) Error() string {
+	return fmt.Sprintf("shutdown: drain timed out with %d active handlers: %v", e.Remaining, e.Err)
+}
+
+func (e *DrainTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// DrainCancelledError is returned by Shutdown when its context was cancelled before the drain completed, e.g. by an
+// operator, as opposed to reaching its deadline. It wraps the context error, so errors.Is(err, context.Canceled)
+// holds.
+type DrainCancelledError struct {
+	// Remaining is the number of handlers that were still active.
+	Remaining int64
+	// Err is the error of the context.
+	Err error
+}
+
+func (e *DrainCancelledError) Error() string {
+	return fmt.Sprintf("shutdown: drain cancelled with %d active handlers: %v", e.Remaining, e.Err)
+}
+
+func (e *DrainCancelledError) Unwrap() error {
+	return e.Err
+}
+
+// drainError wraps err in a DrainTimeoutError or DrainCancelledError if it is the error of ctx.
+func (g *Shutdowner) drainError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || !errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return drainErrorWith(err, g.active.Load())
+}
+
+// drainErrorWith wraps the context error err in a DrainTimeoutError or DrainCancelledError with remaining handlers.
+func drainErrorWith(err error, remaining int64) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &DrainTimeoutError{Remaining: remaining, Err: err}
+	}
+	return &DrainCancelledError{Remaining: remaining, Err: err}
+}
@@ -0,0 +1,43 @@
+package shutdown
+
+import "time"
+
+// estimateWindow is the window over which EstimatedDrainTime measures the rate at which handlers finish.
+const estimateWindow = 10 * time.Second
+
+// rateSample is a sample of the finished counter taken while draining.
+type rateSample struct {
+	at       time.Time
+	finished int64
+}
+
+// EstimatedDrainTime estimates how long it takes the active handlers to finish, from the rate at which handlers
+// finished during the last 10 seconds of the drain, e.g. for a progress display or a Retry-After header. Each call
+// samples the counters, and the start of the shutdown is the first sample. The boolean result is false if shutdown
+// has not begun or if no handler finished during the window, so that there is no rate to estimate from.
+func (g *Shutdowner) EstimatedDrainTime() (time.Duration, bool) {
+	if !g.shuttingDown.Load() {
+		return 0, false
+	}
+	now := time.Now()
+	active := g.active.Load()
+
+	g.mu.Lock()
+	samples := append(g.rateSamples, rateSample{at: now, finished: g.finished.Load()})
+	// keep the last sample before the window, so that the window is covered entirely
+	for len(samples) > 2 && now.Sub(samples[1].at) > estimateWindow {
+		samples = samples[1:]
+	}
+	g.rateSamples = samples
+	g.mu.Unlock()
+
+	if active <= 0 {
+		return 0, true
+	}
+	first, last := samples[0], samples[len(samples)-1]
+	finished, elapsed := last.finished-first.finished, last.at.Sub(first.at)
+	if finished <= 0 || elapsed <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(active) * float64(elapsed) / float64(finished)), true
+}
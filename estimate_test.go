@@ -0,0 +1,63 @@
+package shutdown_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_EstimatedDrainTime(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	if _, ok := shutdowner.EstimatedDrainTime(); ok {
+		t.Errorf("expected no estimate before shutdown")
+	}
+
+	const handlers = 20
+	entered, release := make(chan struct{}), make(chan struct{})
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+	for range handlers {
+		go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		<-entered
+	}
+	_ = shutdowner.BeginShutdown()
+	if _, ok := shutdowner.EstimatedDrainTime(); ok {
+		t.Errorf("expected no estimate before any handler finished")
+	}
+
+	// a steady rate of one handler per 5ms
+	const interval = 5 * time.Millisecond
+	for range handlers / 2 {
+		time.Sleep(interval)
+		release <- struct{}{}
+	}
+	for shutdowner.ActiveCount() != handlers/2 {
+		time.Sleep(time.Millisecond)
+	}
+	estimate, ok := shutdowner.EstimatedDrainTime()
+	if !ok {
+		t.Fatalf("expected an estimate")
+	}
+	// the remaining 10 handlers take about 50ms at this rate, which scheduling delays may stretch
+	if estimate < 10*interval*3/4 || estimate > 10*interval*4 {
+		t.Errorf("expected an estimate of about %v, got %v", 10*interval, estimate)
+	}
+
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if estimate, ok := shutdowner.EstimatedDrainTime(); !ok || estimate != 0 {
+		t.Errorf("expected an estimate of 0 once drained, got %v, %v", estimate, ok)
+	}
+}
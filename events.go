@@ -0,0 +1,119 @@
+package shutdown
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType identifies what happened in an Event.
+type EventType int
+
+const (
+	// EventShutdownBegan is emitted when shutdown begins.
+	EventShutdownBegan EventType = iota + 1
+	// EventDrainCompleted is emitted when a Shutdown call observed all handlers to have returned.
+	EventDrainCompleted
+	// EventDrainTimedOut is emitted when a Shutdown call returned because its context was done.
+	EventDrainTimedOut
+	// EventHandlerStarted is emitted when a handler wrapped with Middleware starts. It is only emitted to the readers of
+	// EventStream, not to the channels returned by Events, and only while there are any, so requests don't pay for it
+	// otherwise.
+	EventHandlerStarted
+	// EventHandlerFinished is emitted when a handler wrapped with Middleware returns. Like EventHandlerStarted, it is only
+	// emitted to the readers of EventStream.
+	EventHandlerFinished
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventShutdownBegan:
+		return "shutdown_began"
+	case EventDrainCompleted:
+		return "drain_completed"
+	case EventDrainTimedOut:
+		return "drain_timed_out"
+	case EventHandlerStarted:
+		return "handler_started"
+	case EventHandlerFinished:
+		return "handler_finished"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a state change of a Shutdowner.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Active is the number of active handlers at the time of the event.
+	Active int64
+	// Cause is the reason for the shutdown as passed to BeginShutdownCause, or ErrShuttingDown if none was given.
+	Cause error
+}
+
+// eventSubscribers holds the channels returned by Events.
+type eventSubscribers struct {
+	handlerSubs atomic.Int32 // the number of subscribers of handler events, read without locking on the hot path
+	mu          sync.Mutex
+	subs        map[chan Event]bool // channel -> whether it receives handler events
+}
+
+// Events subscribes to the events of the Shutdowner. The returned channel has the given buffer size; events are
+// dropped rather than blocking the Shutdowner if the subscriber does not keep up. Call the returned function to
+// unsubscribe, which closes the channel.
+func (g *Shutdowner) Events(buffer int) (<-chan Event, func()) {
+	return g.subscribe(buffer, false)
+}
+
+// subscribe subscribes to the events, including the handler events if handlerEvents is true.
+func (g *Shutdowner) subscribe(buffer int, handlerEvents bool) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	g.events.mu.Lock()
+	if g.events.subs == nil {
+		g.events.subs = make(map[chan Event]bool)
+	}
+	g.events.subs[ch] = handlerEvents
+	if handlerEvents {
+		g.events.handlerSubs.Add(1)
+	}
+	g.events.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			g.events.mu.Lock()
+			delete(g.events.subs, ch)
+			if handlerEvents {
+				g.events.handlerSubs.Add(-1)
+			}
+			g.events.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// emit sends the event to all subscribers that have buffer space left.
+func (g *Shutdowner) emit(e Event) {
+	handlerEvent := e.Type == EventHandlerStarted || e.Type == EventHandlerFinished
+	g.events.mu.Lock()
+	defer g.events.mu.Unlock()
+	for ch, handlerEvents := range g.events.subs {
+		if handlerEvent && !handlerEvents {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// emitHandler emits a handler event if there are subscribers of handler events.
+func (g *Shutdowner) emitHandler(t EventType) {
+	if g.events.handlerSubs.Load() == 0 {
+		return
+	}
+	g.emit(Event{Type: t, Time: time.Now(), Active: g.active.Load()})
+}
@@ -0,0 +1,28 @@
+package shutdown
+
+import "net/http"
+
+// SetTestHookSignalsNotified sets a function that is called by RunUntilSignalWithForce once it is subscribed to the
+// signals, and returns a function that restores the previous hook.
+func SetTestHookSignalsNotified(f func()) (restore func()) {
+	prev := testHookSignalsNotified
+	testHookSignalsNotified = f
+	return func() { testHookSignalsNotified = prev }
+}
+
+// SetTestHookServe sets a function that the middleware of g calls right before each wrapped handler, so that tests can
+// hold handlers in flight until they release them instead of sleeping in the handlers. It must be set before g serves
+// any request.
+func SetTestHookServe(g *Shutdowner, f func(r *http.Request)) {
+	g.testHookServe = f
+}
+
+// Leave counts a handler of g as finished without counting one as started first.
+func Leave(g *Shutdowner) {
+	g.leave(nil)
+}
+
+// Done marks an operation of g as finished without counting one first, bypassing the checks of Leave.
+func Done(g *Shutdowner) {
+	g.done()
+}
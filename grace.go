@@ -0,0 +1,67 @@
+package shutdown
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// WithHandlerGrace configures a grace period per request, which is the time a handler is given to return on its own
+// once shutdown begins. When it has elapsed, the request context is cancelled with ErrGraceExpired as cause, so that
+// long-running handlers can be told to wrap up separately from short ones. A zero or negative grace disables the
+// cancellation for the request. For requests arriving after shutdown began, the grace is counted from the beginning of
+// the shutdown.
+//
+// Handlers are still responsible for actually returning once the context is done, since Shutdown keeps waiting for
+// them.
+func WithHandlerGrace(grace func(r *http.Request) time.Duration) Option {
+	return func(g *Shutdowner) {
+		g.cfg.grace = grace
+	}
+}
+
+// WithTrafficGrace is like WithHandlerGrace, but distinguishes the requests itself: websocket handshakes and other
+// upgrade requests are given the websocket grace, and all other requests the rest grace.
+func WithTrafficGrace(rest, websocket time.Duration) Option {
+	return WithHandlerGrace(func(r *http.Request) time.Duration {
+		if isUpgradeRequest(r) {
+			return websocket
+		}
+		return rest
+	})
+}
+
+// WithMaxHandlerDuration caps the time any handler may run, whether or not shutdown has begun: once d has elapsed since
+// the handler was invoked, the request context is cancelled with ErrMaxHandlerDurationExceeded as cause. Unlike the
+// grace configured with WithHandlerGrace, which is counted from the beginning of the shutdown, the cap keeps long-lived
+// handlers from ever holding up a future drain for longer than d. A zero or negative d disables the cap.
+//
+// As with the grace, handlers are still responsible for actually returning once the context is done.
+func WithMaxHandlerDuration(d time.Duration) Option {
+	return func(g *Shutdowner) {
+		g.cfg.maxHandlerDuration = d
+	}
+}
+
+// graceContext returns a context derived from parent that is cancelled with ErrGraceExpired once grace has elapsed
+// after shutdown began, and a function that must be called to release its resources when the handler returns.
+func (g *Shutdowner) graceContext(parent context.Context, grace time.Duration) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	stop := context.AfterFunc(g.baseContext(), func() {
+		g.mu.Lock()
+		startedAt := g.startedAt
+		g.mu.Unlock()
+
+		t := time.NewTimer(grace - time.Since(startedAt))
+		defer t.Stop()
+		select {
+		case <-t.C:
+			cancel(ErrGraceExpired)
+		case <-ctx.Done():
+		}
+	})
+	return ctx, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
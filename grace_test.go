@@ -0,0 +1,120 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestWithTrafficGrace(t *testing.T) {
+	t.Parallel()
+	const restGrace, wsGrace = 20 * time.Millisecond, 300 * time.Millisecond
+	shutdowner := shutdown.NewShutdowner(shutdown.WithTrafficGrace(restGrace, wsGrace))
+
+	type result struct {
+		elapsed time.Duration
+		cause   error
+	}
+	var began time.Time
+	beganCh := make(chan struct{})
+	serve := func(r *http.Request, entered chan<- struct{}) <-chan result {
+		res := make(chan result, 1)
+		go shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entered <- struct{}{}
+			<-r.Context().Done()
+			<-beganCh
+			res <- result{elapsed: time.Since(began), cause: context.Cause(r.Context())}
+		})).ServeHTTP(httptest.NewRecorder(), r)
+		return res
+	}
+	entered := make(chan struct{}, 2)
+	rest := serve(httptest.NewRequest("GET", "/api", nil), entered)
+	ws := serve(newUpgradeRequest(), entered)
+	<-entered
+	<-entered
+
+	began = time.Now()
+	close(beganCh)
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		res   <-chan result
+		grace time.Duration
+	}{
+		{name: "rest", res: rest, grace: restGrace},
+		{name: "websocket", res: ws, grace: wsGrace},
+	} {
+		res := <-tc.res
+		if !errors.Is(res.cause, shutdown.ErrGraceExpired) {
+			t.Errorf("%s: expected cause %v, got %v", tc.name, shutdown.ErrGraceExpired, res.cause)
+		}
+		if res.elapsed < tc.grace || res.elapsed > tc.grace+200*time.Millisecond {
+			t.Errorf("%s: expected the context to be cancelled after about %s, got %s", tc.name, tc.grace, res.elapsed)
+		}
+	}
+}
+
+func TestWithHandlerGrace_NotCancelledBeforeShutdown(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithHandlerGrace(func(r *http.Request) time.Duration {
+		return time.Millisecond
+	}))
+
+	shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		if err := r.Context().Err(); err != nil {
+			t.Errorf("expected the context to be live before shutdown, got %v", err)
+		}
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestWithHandlerGrace_ArrivingAfterShutdown(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithHandlerGrace(func(r *http.Request) time.Duration {
+		return 10 * time.Millisecond
+	}))
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+			t.Errorf("expected the context to be cancelled once the grace counted from the shutdown elapsed")
+		}
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestWithMaxHandlerDuration(t *testing.T) {
+	t.Parallel()
+	const maxDuration = 20 * time.Millisecond
+	shutdowner := shutdown.NewShutdowner(shutdown.WithMaxHandlerDuration(maxDuration))
+
+	var elapsed time.Duration
+	var cause error
+	shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		<-r.Context().Done()
+		elapsed, cause = time.Since(started), context.Cause(r.Context())
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ws", nil))
+
+	if !errors.Is(cause, shutdown.ErrMaxHandlerDurationExceeded) {
+		t.Errorf("expected cause %v, got %v", shutdown.ErrMaxHandlerDurationExceeded, cause)
+	}
+	if elapsed < maxDuration-time.Millisecond || elapsed > maxDuration+200*time.Millisecond {
+		t.Errorf("expected the context to be cancelled after about %s, got %s", maxDuration, elapsed)
+	}
+	if shutdowner.IsShuttingDown() {
+		t.Errorf("expected the shutdowner not to shut down")
+	}
+}
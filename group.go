@@ -0,0 +1,74 @@
+package shutdown
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// group counts the handlers and other operations Shutdown waits for. Unlike sync.WaitGroup, it allows Add with a
+// positive delta while the counter is zero and Wait is in progress, which happens whenever a request arrives as
+// shutdown begins. Wait returns once the counter is observed to be zero. The counter is updated atomically, and the
+// mutex is only taken by Wait and when the counter drops to zero while Wait is in progress.
+type group struct {
+	n       atomic.Int64
+	waiting atomic.Bool
+
+	mu      sync.Mutex
+	waiters chan struct{} // closed when the counter drops to zero, nil if nobody waits
+}
+
+// Add adds delta to the counter and reports whether it did. A delta that would make the counter negative is ignored
+// and reported as false, leaving it to the caller to report the misuse.
+func (w *group) Add(delta int) bool {
+	var n int64
+	if delta < 0 {
+		// a negative delta must never be applied, not even transiently, or a concurrent Add could see a false zero
+		for {
+			n = w.n.Load() + int64(delta)
+			if n < 0 {
+				return false
+			}
+			if w.n.CompareAndSwap(n-int64(delta), n) {
+				break
+			}
+		}
+	} else {
+		n = w.n.Add(int64(delta))
+	}
+	if n == 0 && w.waiting.Load() {
+		w.mu.Lock()
+		// the counter may have increased again in the meantime, in which case the waiters must keep waiting
+		if w.waiters != nil && w.n.Load() == 0 {
+			close(w.waiters)
+			w.waiters = nil
+			w.waiting.Store(false)
+		}
+		w.mu.Unlock()
+	}
+	return true
+}
+
+// Done decrements the counter by one and reports whether it did, see Add.
+func (w *group) Done() bool {
+	return w.Add(-1)
+}
+
+// Wait blocks until the counter is zero.
+func (w *group) Wait() {
+	w.mu.Lock()
+	// announced before the counter is checked, so that Add either sees the announcement or Wait sees the zero
+	w.waiting.Store(true)
+	if w.n.Load() == 0 {
+		if w.waiters == nil {
+			w.waiting.Store(false)
+		}
+		w.mu.Unlock()
+		return
+	}
+	if w.waiters == nil {
+		w.waiters = make(chan struct{})
+	}
+	waiters := w.waiters
+	w.mu.Unlock()
+	<-waiters
+}
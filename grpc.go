@@ -0,0 +1,56 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// GRPCServer is the subset of the methods of *grpc.Server used by ShutdownWithGRPC. It is an interface to avoid the
+// dependency on the grpc module.
+type GRPCServer interface {
+	GracefulStop()
+	Stop()
+}
+
+// ShutdownWithGRPC stops the gRPC server gracefully and shuts down the shutdowner concurrently, waiting for both to
+// finish. If ctx is done before GracefulStop returns, the server is stopped forcefully with Stop. If ctx has no
+// deadline, both are bounded by the timeout configured with WithDrainTimeout, if any. Any errors that occurred are
+// returned with errors.Join. If the guard configured with WithShutdownGuard vetoes the shutdown, the gRPC server is not
+// stopped and the guard's error is returned.
+func (g *Shutdowner) ShutdownWithGRPC(ctx context.Context, gs GRPCServer) error {
+	ctx, cancel := g.withDrainTimeout(ctx)
+	defer cancel()
+
+	if err := g.begin(ctx, nil, true); err != nil {
+		return err
+	}
+
+	var grpcErr, shutdownerErr error
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		shutdownerErr = g.Shutdown(ctx)
+	}()
+
+	stopped := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		gs.Stop()
+		<-stopped
+		grpcErr = fmt.Errorf("shutdown: grpc server stopped forcefully: %w", ctx.Err())
+	}
+	wg.Wait()
+
+	return errors.Join(grpcErr, shutdownerErr)
+}
@@ -0,0 +1,66 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+// fakeGRPCServer blocks in GracefulStop until it is released or Stop is called.
+type fakeGRPCServer struct {
+	release chan struct{}
+	stopped atomic.Bool
+}
+
+func newFakeGRPCServer() *fakeGRPCServer {
+	return &fakeGRPCServer{release: make(chan struct{})}
+}
+
+func (s *fakeGRPCServer) GracefulStop() {
+	<-s.release
+}
+
+func (s *fakeGRPCServer) Stop() {
+	s.stopped.Store(true)
+	close(s.release)
+}
+
+func TestShutdowner_ShutdownWithGRPC(t *testing.T) {
+	t.Parallel()
+
+	t.Run("graceful stop", func(t *testing.T) {
+		t.Parallel()
+		var shutdowner shutdown.Shutdowner
+		gs := newFakeGRPCServer()
+		close(gs.release)
+
+		if err := shutdowner.ShutdownWithGRPC(context.Background(), gs); err != nil {
+			t.Errorf("no error expected but got %v", err)
+		}
+		if gs.stopped.Load() {
+			t.Errorf("expected Stop not to be called")
+		}
+	})
+
+	t.Run("forced stop", func(t *testing.T) {
+		t.Parallel()
+		var shutdowner shutdown.Shutdowner
+		gs := newFakeGRPCServer()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+		if err := shutdowner.ShutdownWithGRPC(ctx, gs); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected %v, but got %v", context.DeadlineExceeded, err)
+		}
+		if !gs.stopped.Load() {
+			t.Errorf("expected Stop to be called")
+		}
+		if !shutdowner.IsDrained() {
+			t.Errorf("expected handlers to be drained")
+		}
+	})
+}
@@ -0,0 +1,19 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package shutdown
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// HandoffListener is not supported on this operating system and returns an error wrapping errors.ErrUnsupported.
+func (g *Shutdowner) HandoffListener(l *net.TCPListener, to string) error {
+	return fmt.Errorf("shutdown: handing off listener: %w", errors.ErrUnsupported)
+}
+
+// ReceiveListener is not supported on this operating system and returns an error wrapping errors.ErrUnsupported.
+func ReceiveListener(ul *net.UnixListener) (net.Listener, error) {
+	return nil, fmt.Errorf("shutdown: receiving listener: %w", errors.ErrUnsupported)
+}
@@ -0,0 +1,87 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package shutdown
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// HandoffListener passes the file descriptor of l over the Unix domain socket named to, on which a successor process
+// waits with ReceiveListener, and begins the shutdown once the successor has it. The successor then accepts the
+// connections of the listener, while the handlers of this process drain, so an upgrade to a new binary drops no
+// connections. Call Shutdown or ShutdownWithServer afterward as usual; closing l in this process does not close the
+// listener of the successor. If the guard configured with WithShutdownGuard vetoes the shutdown, the listener has
+// already been handed off and both processes accept connections until this one is shut down.
+//
+// HandoffListener is supported on Linux and the BSDs, including macOS, and returns an error wrapping
+// errors.ErrUnsupported elsewhere.
+func (g *Shutdowner) HandoffListener(l *net.TCPListener, to string) error {
+	if err := sendListener(l, to); err != nil {
+		return fmt.Errorf("shutdown: handing off listener: %w", err)
+	}
+	return g.BeginShutdown()
+}
+
+// ReceiveListener accepts a single connection on ul, receives the listener handed off by HandoffListener of a
+// predecessor process over it and returns it. ul is not closed.
+func ReceiveListener(ul *net.UnixListener) (net.Listener, error) {
+	l, err := receiveListener(ul)
+	if err != nil {
+		return nil, fmt.Errorf("shutdown: receiving listener: %w", err)
+	}
+	return l, nil
+}
+
+func sendListener(l *net.TCPListener, to string) error {
+	f, err := l.File()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: to, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// at least one byte of regular data has to accompany the descriptor
+	_, _, err = conn.WriteMsgUnix([]byte{0}, syscall.UnixRights(int(f.Fd())), nil)
+	return err
+}
+
+func receiveListener(ul *net.UnixListener) (net.Listener, error) {
+	conn, err := ul.AcceptUnix()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	buf, oob := make([]byte, 1), make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) != 1 {
+		return nil, errors.New("no file descriptor received")
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(fds) != 1 {
+		for _, fd := range fds {
+			_ = syscall.Close(fd)
+		}
+		return nil, fmt.Errorf("received %d file descriptors instead of one", len(fds))
+	}
+	f := os.NewFile(uintptr(fds[0]), "handoff")
+	defer f.Close()
+	// the listener uses a duplicate of the descriptor
+	return net.FileListener(f)
+}
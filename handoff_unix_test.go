@@ -0,0 +1,92 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package shutdown_test
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_HandoffListener(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := l.Addr().String()
+	name := filepath.Join(t.TempDir(), "handoff.sock")
+	ul, err := net.ListenUnix("unix", &net.UnixAddr{Name: name, Net: "unix"})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ul.Close()
+
+	// the successor's side
+	type result struct {
+		l   net.Listener
+		err error
+	}
+	received := make(chan result, 1)
+	go func() {
+		l, err := shutdown.ReceiveListener(ul)
+		received <- result{l: l, err: err}
+	}()
+
+	if err := shutdowner.HandoffListener(l, name); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if !shutdowner.IsShuttingDown() {
+		t.Errorf("expected the shutdown to begin after the handoff")
+	}
+	res := <-received
+	if res.err != nil {
+		t.Fatalf("no error expected but got %v", res.err)
+	}
+	defer res.l.Close()
+	if got := res.l.Addr().String(); got != addr {
+		t.Errorf("expected the listener on %s, got %s", addr, got)
+	}
+
+	// the predecessor stops listening, but the successor still accepts connections
+	_ = l.Close()
+	accepted := make(chan error, 1)
+	go func() {
+		conn, err := res.l.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		accepted <- err
+	}()
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatalf("failed to connect to the handed off listener: %v", err)
+	}
+	conn.Close()
+	if err := <-accepted; err != nil {
+		t.Errorf("expected the successor to accept the connection, got %v", err)
+	}
+}
+
+func TestShutdowner_HandoffListener_NoSuccessor(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+
+	if err := shutdowner.HandoffListener(l, filepath.Join(t.TempDir(), "missing.sock")); err == nil {
+		t.Errorf("expected an error without a successor")
+	}
+	if shutdowner.IsShuttingDown() {
+		t.Errorf("expected the shutdown not to begin")
+	}
+}
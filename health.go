@@ -0,0 +1,59 @@
+package shutdown
+
+import "net/http"
+
+// untrackedHandler is implemented by the handlers of the package that Middleware never tracks.
+type untrackedHandler interface {
+	http.Handler
+	untracked()
+}
+
+// healthHandler serves the liveness or readiness probe of a Shutdowner.
+type healthHandler struct {
+	g         *Shutdowner
+	readiness bool
+}
+
+// LivenessHandler returns a handler for liveness probes, e.g. of Kubernetes, that responds with 200 OK as long as the
+// process is up, including during the drain, so that the process is not killed mid-drain for failing its liveness
+// probe. Use ReadinessHandler to take the process out of load balancing during the drain.
+//
+// The handler is never tracked by Middleware, see WithSkipPaths for handlers that are wrapped as part of a larger
+// handler like a http.ServeMux.
+func (g *Shutdowner) LivenessHandler() http.Handler {
+	return healthHandler{g: g}
+}
+
+// ReadinessHandler returns a handler for readiness probes, e.g. of Kubernetes, that responds with 200 OK until shutdown
+// begins and with 503 Service Unavailable afterward, so that no new traffic is routed to the process during the drain.
+//
+// The handler is never tracked by Middleware, see WithSkipPaths for handlers that are wrapped as part of a larger
+// handler like a http.ServeMux.
+func (g *Shutdowner) ReadinessHandler() http.Handler {
+	return healthHandler{g: g, readiness: true}
+}
+
+func (healthHandler) untracked() {}
+
+func (h healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.readiness && h.g.shuttingDown.Load() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// WithSkipPaths configures the middleware to pass requests for the given paths through to the wrapped handler without
+// tracking or rejecting them, e.g. for the probe handlers when they are served by the same http.ServeMux as the
+// application. It is a shorthand for WithDrainPredicate.
+func WithSkipPaths(paths ...string) Option {
+	skip := make(map[string]struct{}, len(paths))
+	for _, p := range paths {
+		skip[p] = struct{}{}
+	}
+	return WithDrainPredicate(func(r *http.Request) bool {
+		_, ok := skip[r.URL.Path]
+		return !ok
+	})
+}
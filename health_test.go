@@ -0,0 +1,46 @@
+package shutdown_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_HealthHandlers(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithSkipPaths("/livez", "/readyz"))
+
+	mux := http.NewServeMux()
+	mux.Handle("/livez", shutdowner.LivenessHandler())
+	mux.Handle("/readyz", shutdowner.ReadinessHandler())
+	wrappedMux := shutdowner.Middleware(mux)
+	wrappedDirectly := map[string]http.Handler{
+		"/livez":  shutdowner.Middleware(shutdowner.LivenessHandler()),
+		"/readyz": shutdowner.Middleware(shutdowner.ReadinessHandler()),
+	}
+
+	check := func(state string, wantLive, wantReady int) {
+		t.Helper()
+		for path, want := range map[string]int{"/livez": wantLive, "/readyz": wantReady} {
+			for name, h := range map[string]http.Handler{"mux": wrappedMux, "direct": wrappedDirectly[path]} {
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+				if rec.Code != want {
+					t.Errorf("%s, %s %s: expected status %d, got %d", state, name, path, want, rec.Code)
+				}
+			}
+		}
+	}
+
+	check("running", http.StatusOK, http.StatusOK)
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	check("draining", http.StatusOK, http.StatusServiceUnavailable)
+
+	if got := shutdowner.Stats(); got.Started != 0 || got.Rejected != 0 {
+		t.Errorf("expected probe requests not to be tracked, got %+v", got)
+	}
+}
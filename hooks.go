@@ -0,0 +1,234 @@
+package shutdown
+
+import (
+	"context"
+	"sync"
+)
+
+// OnShutdown registers f to be called when shutdown begins, i.e. the first time Shutdown or ShutdownWithServer is
+// invoked. f is called in its own goroutine with the context passed to that invocation, and Shutdown waits for it to
+// return, just like it waits for handlers. There are no ordering guarantees between several registered functions,
+// unless WithSequentialShutdownHooks is used. If shutdown has already begun, f is called immediately, but it is not
+// waited on anymore.
+func (g *Shutdowner) OnShutdown(f func(ctx context.Context)) {
+	g.OnShutdownErr(func(ctx context.Context) error {
+		f(ctx)
+		return nil
+	})
+}
+
+// RegisterOnShutdown is like OnShutdown, but takes a function without arguments like http.Server.RegisterOnShutdown,
+// e.g. to flush a buffer when shutdown begins. Unlike with http.Server, Shutdown waits for f to return. f is called
+// at most once, regardless of how often Shutdown is called.
+func (g *Shutdowner) RegisterOnShutdown(f func()) {
+	g.OnShutdown(func(context.Context) { f() })
+}
+
+// OnShutdownErr is like OnShutdown, but f can report an error. The errors of all callbacks are joined in the HookErr
+// field of the Report. An error does not prevent the other callbacks from being called.
+func (g *Shutdowner) OnShutdownErr(f func(ctx context.Context) error) {
+	g.mu.Lock()
+	if !g.shuttingDown.Load() {
+		g.hooks = append(g.hooks, f)
+		g.mu.Unlock()
+		return
+	}
+	ctx := g.beginCtx
+	g.mu.Unlock()
+	go g.recordHookErr(f(ctx))
+}
+
+// OnShutdownNamed is like OnShutdown, but registers f under name. With WithDedupeHooks, only the first callback
+// registered under a name is called, e.g. so that a goodbye message is not sent twice if the setup registering it
+// accidentally runs twice. Without it, the name has no effect.
+func (g *Shutdowner) OnShutdownNamed(name string, f func(ctx context.Context)) {
+	if g.cfg.dedupeHooks {
+		g.mu.Lock()
+		_, dup := g.hookNames[name]
+		if !dup {
+			if g.hookNames == nil {
+				g.hookNames = make(map[string]struct{})
+			}
+			g.hookNames[name] = struct{}{}
+		}
+		g.mu.Unlock()
+		if dup {
+			return
+		}
+	}
+	g.OnShutdown(f)
+}
+
+// WithDedupeHooks configures OnShutdownNamed to ignore callbacks registered under a name that has already been
+// registered, so that each named callback is called only once.
+func WithDedupeHooks() Option {
+	return func(g *Shutdowner) {
+		g.cfg.dedupeHooks = true
+	}
+}
+
+// WithSequentialShutdownHooks configures the Shutdowner to call the callbacks registered with OnShutdown and its
+// variants one after another in registration order, instead of concurrently, e.g. to stop accepting new sessions
+// before closing the existing ones. A callback that returns an error does not stop the following ones.
+func WithSequentialShutdownHooks() Option {
+	return func(g *Shutdowner) {
+		g.cfg.sequentialHooks = true
+	}
+}
+
+// OnShutdownWithTarget is like OnShutdown, but additionally passes the address of a replacement instance as reported by
+// the function configured with WithMigrationTarget. Handlers of long-lived connections can use it to suggest to their
+// clients where to reconnect, e.g. with a "reconnect to X" websocket message. target is empty if no migration target is
+// configured.
+func (g *Shutdowner) OnShutdownWithTarget(f func(ctx context.Context, target string)) {
+	g.OnShutdown(func(ctx context.Context) {
+		var target string
+		if g.cfg.migrationTarget != nil {
+			target = g.cfg.migrationTarget()
+		}
+		f(ctx, target)
+	})
+}
+
+// OnShutdownWithStats is like OnShutdown, but additionally passes the Stats at the moment shutdown began, e.g. to only
+// send a goodbye message if many clients are connected. All callbacks receive the same snapshot, regardless of when
+// they are called.
+func (g *Shutdowner) OnShutdownWithStats(f func(ctx context.Context, s Stats)) {
+	g.OnShutdown(func(ctx context.Context) {
+		g.mu.Lock()
+		s := g.statsAtStart
+		g.mu.Unlock()
+		f(ctx, s)
+	})
+}
+
+// WithMigrationTarget configures the function that reports the address of a replacement instance to the callbacks
+// registered with OnShutdownWithTarget. It is called once per callback when shutdown begins.
+func WithMigrationTarget(target func() string) Option {
+	return func(g *Shutdowner) {
+		g.cfg.migrationTarget = target
+	}
+}
+
+// WithShutdownConcurrency configures the maximum number of callbacks registered with OnShutdown and its variants that
+// are called concurrently when shutdown begins, so that many callbacks don't spawn as many goroutines at once. Callbacks
+// that have not been started when the context passed to the shutdown is done are skipped. By default, all callbacks are
+// called concurrently.
+func WithShutdownConcurrency(n int) Option {
+	return func(g *Shutdowner) {
+		g.cfg.shutdownConcurrency = n
+	}
+}
+
+// AfterDrain registers f to be called when the first Shutdown call returns, regardless of whether the drain completed
+// or timed out. f is called before that Shutdown call returns. If a Shutdown call has already returned, AfterDrain has
+// no effect. If the drain is abandoned with AbandonDrain, f is not called.
+func (g *Shutdowner) AfterDrain(f func()) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.afterDrainRun {
+		return
+	}
+	g.afterDrain = append(g.afterDrain, func(err error, remaining int64) { f() })
+}
+
+// OnDrainSuccess is like AfterDrain, but f is only called if the drain completed in time.
+func (g *Shutdowner) OnDrainSuccess(f func()) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.afterDrainRun {
+		return
+	}
+	g.afterDrain = append(g.afterDrain, func(err error, remaining int64) {
+		if err == nil {
+			f()
+		}
+	})
+}
+
+// OnDrainTimeout is like AfterDrain, but f is only called if the drain did not complete in time. remaining is the number
+// of handlers that were still active.
+func (g *Shutdowner) OnDrainTimeout(f func(remaining int64)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.afterDrainRun {
+		return
+	}
+	g.afterDrain = append(g.afterDrain, func(err error, remaining int64) {
+		if err != nil {
+			f(remaining)
+		}
+	})
+}
+
+// runAfterDrain calls the callbacks registered with AfterDrain and its variants, if no Shutdown call has done so yet.
+func (g *Shutdowner) runAfterDrain(err error, remaining int64) {
+	g.mu.Lock()
+	callbacks := g.afterDrain
+	g.afterDrain = nil
+	g.afterDrainRun = true
+	g.mu.Unlock()
+
+	for _, f := range callbacks {
+		f(err, remaining)
+	}
+}
+
+// runHooks calls the given hooks, concurrently or sequentially as configured, and returns a channel that is closed once
+// all of them have returned.
+func (g *Shutdowner) runHooks(ctx context.Context, hooks []func(ctx context.Context) error) chan struct{} {
+	done := make(chan struct{})
+	if g.cfg.sequentialHooks {
+		go func() {
+			defer close(done)
+			for _, h := range hooks {
+				g.recordHookErr(h(ctx))
+			}
+		}()
+		return done
+	}
+
+	var wg sync.WaitGroup
+	if n := g.cfg.shutdownConcurrency; n > 0 && n < len(hooks) {
+		queue := make(chan func(ctx context.Context) error, len(hooks))
+		for _, h := range hooks {
+			queue <- h
+		}
+		close(queue)
+		wg.Add(n)
+		for range n {
+			go func() {
+				defer wg.Done()
+				for h := range queue {
+					if ctx.Err() != nil {
+						continue
+					}
+					g.recordHookErr(h(ctx))
+				}
+			}()
+		}
+	} else {
+		wg.Add(len(hooks))
+		for _, h := range hooks {
+			go func() {
+				defer wg.Done()
+				g.recordHookErr(h(ctx))
+			}()
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// recordHookErr records the error returned by a hook for the Report.
+func (g *Shutdowner) recordHookErr(err error) {
+	if err == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.hookErrs = append(g.hookErrs, err)
+}
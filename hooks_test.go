@@ -0,0 +1,343 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_OnShutdownWithTarget(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithMigrationTarget(func() string { return "ws://replacement:8080" }))
+
+	targets := make(chan string, 2)
+	shutdowner.OnShutdownWithTarget(func(ctx context.Context, target string) {
+		targets <- target
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	// Shutdown waits for the hooks registered before shutdown began
+	select {
+	case target := <-targets:
+		if target != "ws://replacement:8080" {
+			t.Errorf("expected target %q, got %q", "ws://replacement:8080", target)
+		}
+	default:
+		t.Fatalf("expected hook to have been called before Shutdown returned")
+	}
+
+	// hooks registered after shutdown began are called immediately
+	shutdowner.OnShutdownWithTarget(func(ctx context.Context, target string) {
+		targets <- target
+	})
+	select {
+	case target := <-targets:
+		if target != "ws://replacement:8080" {
+			t.Errorf("expected target %q, got %q", "ws://replacement:8080", target)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected hook registered after shutdown to be called")
+	}
+}
+
+func TestShutdowner_OnShutdownWithTarget_NoTarget(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	var got *string
+	shutdowner.OnShutdownWithTarget(func(ctx context.Context, target string) {
+		got = &target
+	})
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if got == nil || *got != "" {
+		t.Errorf("expected hook to be called with an empty target")
+	}
+}
+
+func TestShutdowner_OnShutdownWithStats(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	release := make(chan struct{})
+	var entered sync.WaitGroup
+	var finished sync.WaitGroup
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			entered.Done()
+			<-release
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fast", nil))
+	for range 3 {
+		entered.Add(1)
+		finished.Add(1)
+		go func() {
+			defer finished.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+		}()
+	}
+	entered.Wait()
+
+	stats := make(chan shutdown.Stats, 2)
+	shutdowner.OnShutdownWithStats(func(ctx context.Context, s shutdown.Stats) {
+		// handlers finishing while the hook runs don't change the snapshot
+		close(release)
+		finished.Wait()
+		stats <- s
+	})
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	want := shutdown.Stats{Active: 3, Started: 4, Finished: 1}
+	if got := <-stats; got != want {
+		t.Errorf("expected stats %+v, got %+v", want, got)
+	}
+
+	shutdowner.OnShutdownWithStats(func(ctx context.Context, s shutdown.Stats) {
+		stats <- s
+	})
+	if got := <-stats; got != want {
+		t.Errorf("expected a hook registered after shutdown to get the stats at begin %+v, got %+v", want, got)
+	}
+}
+
+func TestShutdowner_RegisterOnShutdown(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	var calls atomic.Int32
+	shutdowner.RegisterOnShutdown(func() { calls.Add(1) })
+	for range 3 {
+		if err := shutdowner.Shutdown(context.Background()); err != nil {
+			t.Fatalf("no error expected but got %v", err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected the hook to be called once, got %d calls", got)
+	}
+
+	late := make(chan struct{})
+	shutdowner.RegisterOnShutdown(func() { close(late) })
+	select {
+	case <-late:
+	case <-time.After(time.Second):
+		t.Fatalf("expected hook registered after shutdown to be called")
+	}
+}
+
+func TestWithDedupeHooks(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name      string
+		opts      []shutdown.Option
+		wantCalls int32
+	}{
+		{name: "dedupe", opts: []shutdown.Option{shutdown.WithDedupeHooks()}, wantCalls: 1},
+		{name: "no dedupe", wantCalls: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			shutdowner := shutdown.NewShutdowner(tc.opts...)
+			var goodbyes, others atomic.Int32
+			for range 2 {
+				shutdowner.OnShutdownNamed("goodbye", func(ctx context.Context) { goodbyes.Add(1) })
+			}
+			shutdowner.OnShutdownNamed("other", func(ctx context.Context) { others.Add(1) })
+			if err := shutdowner.Shutdown(context.Background()); err != nil {
+				t.Fatalf("no error expected but got %v", err)
+			}
+			if got := goodbyes.Load(); got != tc.wantCalls {
+				t.Errorf("expected %d calls of the duplicate hook, got %d", tc.wantCalls, got)
+			}
+			if got := others.Load(); got != 1 {
+				t.Errorf("expected 1 call of the other hook, got %d", got)
+			}
+		})
+	}
+}
+
+func TestWithSequentialShutdownHooks(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithSequentialShutdownHooks())
+
+	var mu sync.Mutex
+	var order []int
+	errFirst, errThird := errors.New("first"), errors.New("third")
+	for i, err := range []error{errFirst, nil, errThird, nil} {
+		shutdowner.OnShutdownErr(func(ctx context.Context) error {
+			// give later hooks a chance to overtake if they were run concurrently
+			time.Sleep(time.Duration(4-i) * time.Millisecond)
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			return err
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []int{0, 1, 2, 3}; !slices.Equal(order, want) {
+		t.Errorf("expected hooks to run in order %v, got %v", want, order)
+	}
+	report, _ := shutdowner.Report()
+	if !errors.Is(report.HookErr, errFirst) || !errors.Is(report.HookErr, errThird) {
+		t.Errorf("expected the report to aggregate the hook errors, got %v", report.HookErr)
+	}
+}
+
+func TestOnShutdownErr_Concurrent(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	errHook := errors.New("hook failed")
+	var called atomic.Int32
+	shutdowner.OnShutdownErr(func(ctx context.Context) error {
+		called.Add(1)
+		return errHook
+	})
+	shutdowner.OnShutdown(func(ctx context.Context) {
+		called.Add(1)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Fatalf("hook errors must not fail the drain, got %v", err)
+	}
+	if got := called.Load(); got != 2 {
+		t.Errorf("expected both hooks to be called, got %d", got)
+	}
+	if report, _ := shutdowner.Report(); !errors.Is(report.HookErr, errHook) {
+		t.Errorf("expected the report to contain the hook error, got %v", report.HookErr)
+	}
+}
+
+func TestShutdowner_DrainOutcomeCallbacks(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name          string
+		stuck         bool
+		wantSuccess   bool
+		wantRemaining int64
+	}{
+		{name: "drained", wantSuccess: true, wantRemaining: -1},
+		{name: "timed out", stuck: true, wantRemaining: 1},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var shutdowner shutdown.Shutdowner
+
+			var after, success int
+			remaining := int64(-1)
+			shutdowner.AfterDrain(func() { after++ })
+			shutdowner.OnDrainSuccess(func() { success++ })
+			shutdowner.OnDrainTimeout(func(r int64) { remaining = r })
+
+			release := make(chan struct{})
+			defer close(release)
+			if tc.stuck {
+				entered := make(chan struct{})
+				go shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					close(entered)
+					<-release
+				})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+				<-entered
+			}
+
+			for range 2 {
+				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+				_ = shutdowner.Shutdown(ctx)
+				cancel()
+			}
+
+			if after != 1 {
+				t.Errorf("expected AfterDrain callback to be called once, got %d", after)
+			}
+			if want := map[bool]int{true: 1, false: 0}[tc.wantSuccess]; success != want {
+				t.Errorf("expected OnDrainSuccess callback to be called %d times, got %d", want, success)
+			}
+			if remaining != tc.wantRemaining {
+				t.Errorf("expected OnDrainTimeout callback to report %d remaining, got %d", tc.wantRemaining, remaining)
+			}
+		})
+	}
+}
+
+func TestWithShutdownConcurrency(t *testing.T) {
+	t.Parallel()
+	const limit, hooks = 4, 100
+	shutdowner := shutdown.NewShutdowner(shutdown.WithShutdownConcurrency(limit))
+
+	var running, peak, called atomic.Int32
+	for range hooks {
+		shutdowner.OnShutdown(func(ctx context.Context) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			called.Add(1)
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if got := called.Load(); got != hooks {
+		t.Errorf("expected %d hooks to be called, got %d", hooks, got)
+	}
+	if got := peak.Load(); got > limit {
+		t.Errorf("expected at most %d hooks to run concurrently, got %d", limit, got)
+	}
+}
+
+func TestWithShutdownConcurrency_BoundedByContext(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithShutdownConcurrency(1))
+
+	var called atomic.Int32
+	for range 3 {
+		shutdowner.OnShutdown(func(ctx context.Context) {
+			called.Add(1)
+			<-ctx.Done()
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_ = shutdowner.Shutdown(ctx)
+	time.Sleep(20 * time.Millisecond)
+	if got := called.Load(); got != 1 {
+		t.Errorf("expected the hooks not started before the context was done to be skipped, got %d calls", got)
+	}
+}
@@ -0,0 +1,113 @@
+package shutdown
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// labelState is the drain state of the handlers wrapped with MiddlewareWithLabel for one label.
+type labelState struct {
+	counters
+
+	mu       sync.Mutex
+	draining bool
+	wg       sync.WaitGroup
+	done     chan struct{}
+	drained  chan struct{} // closed when the handlers have finished after draining began, nil before
+}
+
+// label returns the state of label, creating it if necessary.
+func (g *Shutdowner) label(label string) *labelState {
+	if ls, ok := g.labels.Load(label); ok {
+		return ls.(*labelState)
+	}
+	ls, _ := g.labels.LoadOrStore(label, &labelState{done: make(chan struct{})})
+	return ls.(*labelState)
+}
+
+// enter counts a handler of the label as active, unless the label is draining.
+func (ls *labelState) enter() bool {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.draining {
+		return false
+	}
+	ls.wg.Add(1)
+	ls.active.Add(1)
+	ls.started.Add(1)
+	return true
+}
+
+// leave counts a handler of the label as finished.
+func (ls *labelState) leave() {
+	ls.active.Add(-1)
+	ls.finished.Add(1)
+	ls.wg.Done()
+}
+
+// MiddlewareWithLabel is like Middleware, but additionally tags the handler with label, e.g. the tenant it serves, so
+// that ShutdownLabel can drain the handlers of the label while the others keep running. Shutdown still drains all
+// handlers. After ShutdownLabel was called for the label, new requests are rejected with 503 Service Unavailable.
+func (g *Shutdowner) MiddlewareWithLabel(label string, next http.Handler) http.Handler {
+	ls := g.label(label)
+	tracked := g.Middleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ls.enter() {
+			ls.rejected.Add(1)
+			g.rejected.Add(1)
+			if g.cfg.metrics != nil {
+				g.cfg.metrics.RequestRejected()
+			}
+			g.serveRejection(w, r)
+			return
+		}
+		defer ls.leave()
+		tracked.ServeHTTP(w, r)
+	})
+}
+
+// ShutdownLabel drains the handlers wrapped with MiddlewareWithLabel for label: it rejects new requests for the label,
+// closes the channel returned by LabelDone, and waits for the active handlers of the label to finish. If ctx is done
+// before, it returns a *DrainTimeoutError or *DrainCancelledError like Shutdown, with the number of remaining handlers
+// of the label. It does not begin the shutdown of g.
+func (g *Shutdowner) ShutdownLabel(ctx context.Context, label string) error {
+	ls := g.label(label)
+	ls.mu.Lock()
+	if !ls.draining {
+		ls.draining = true
+		close(ls.done)
+		// a single waiter shared by all calls, so that calls timing out do not leave goroutines behind
+		ls.drained = make(chan struct{})
+		go func(drained chan struct{}) {
+			ls.wg.Wait()
+			close(drained)
+		}(ls.drained)
+	}
+	drained := ls.drained
+	ls.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return drainErrorWith(ctx.Err(), ls.active.Load())
+	}
+}
+
+// LabelDone returns a channel that is closed when ShutdownLabel is called for label, e.g. so that the websocket
+// handlers of an evicted tenant can close their connections.
+func (g *Shutdowner) LabelDone(label string) <-chan struct{} {
+	return g.label(label).done
+}
+
+// LabelStats returns the counters per label of the handlers wrapped with MiddlewareWithLabel. Rejected counts the
+// requests rejected because ShutdownLabel was called for the label, which are also counted as rejected by Stats.
+func (g *Shutdowner) LabelStats() map[string]Stats {
+	stats := make(map[string]Stats)
+	g.labels.Range(func(label, ls any) bool {
+		stats[label.(string)] = ls.(*labelState).stats()
+		return true
+	})
+	return stats
+}
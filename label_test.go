@@ -0,0 +1,120 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_ShutdownLabel(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	entered := make(chan struct{}, 2)
+	releaseB := make(chan struct{})
+	newHandler := func(tenant string) http.Handler {
+		return shutdowner.MiddlewareWithLabel(tenant, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entered <- struct{}{}
+			if tenant == "a" {
+				<-shutdowner.LabelDone("a")
+			} else {
+				<-releaseB
+			}
+		}))
+	}
+	handlerA, handlerB := newHandler("a"), newHandler("b")
+	doneA, doneB := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(doneA)
+		handlerA.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	go func() {
+		defer close(doneB)
+		handlerB.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	<-entered
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.ShutdownLabel(ctx, "a"); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	<-doneA
+	select {
+	case <-doneB:
+		t.Fatalf("expected the handler of the other label to keep running")
+	default:
+	}
+	if shutdowner.IsShuttingDown() {
+		t.Errorf("expected the shutdown of a label not to begin the shutdown")
+	}
+
+	rec := httptest.NewRecorder()
+	handlerA.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected new requests of the drained label to be rejected, got status %d", rec.Code)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	var timeoutErr *shutdown.DrainTimeoutError
+	if err := shutdowner.ShutdownLabel(timeoutCtx, "b"); !errors.As(err, &timeoutErr) || timeoutErr.Remaining != 1 {
+		t.Errorf("expected a drain timeout with 1 remaining handler, got %v", err)
+	}
+	stats := shutdowner.LabelStats()
+	if want := (shutdown.Stats{Started: 1, Finished: 1, Rejected: 1}); stats["a"] != want {
+		t.Errorf("expected stats %+v for label a, got %+v", want, stats["a"])
+	}
+	if want := (shutdown.Stats{Active: 1, Started: 1}); stats["b"] != want {
+		t.Errorf("expected stats %+v for label b, got %+v", want, stats["b"])
+	}
+	if got := shutdowner.Stats().Rejected; got != 1 {
+		t.Errorf("expected the labelled rejection to be counted by Stats, got %d", got)
+	}
+
+	close(releaseB)
+	<-doneB
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+}
+
+func TestShutdowner_ShutdownLabel_TimeoutsShareWaiter(t *testing.T) {
+	// not parallel, so that the number of goroutines is stable
+	var shutdowner shutdown.Shutdowner
+	entered, release := make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		shutdowner.MiddlewareWithLabel("a", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(entered)
+			<-release
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	<-entered
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	before := runtime.NumGoroutine()
+	for range 100 {
+		if err := shutdowner.ShutdownLabel(ctx, "a"); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected %v, got %v", context.Canceled, err)
+		}
+	}
+	if after := runtime.NumGoroutine(); after > before+10 {
+		t.Errorf("expected the timed out calls not to leave goroutines behind, got %d goroutines, %d before", after, before)
+	}
+
+	close(release)
+	<-done
+	if err := shutdowner.ShutdownLabel(context.Background(), "a"); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Lifecycle manages the lifecycle of an http.Server together with a Shutdowner, so that integration test frameworks
+// and similar tools can start and stop a real server uniformly. The handler of the server is expected to be wrapped
+// with the Middleware of the Shutdowner.
+type Lifecycle struct {
+	server *http.Server
+	g      *Shutdowner
+
+	mu       sync.Mutex
+	listener net.Listener
+	served   chan error
+}
+
+// NewLifecycle returns a Lifecycle for server and g.
+func NewLifecycle(server *http.Server, g *Shutdowner) *Lifecycle {
+	return &Lifecycle{server: server, g: g}
+}
+
+// Start listens on the TCP address of the server, an arbitrary port if it is empty, and serves in a new goroutine. It
+// returns once the server accepts connections. Start must be called at most once.
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.listener != nil {
+		return errors.New("shutdown: lifecycle already started")
+	}
+
+	addr := l.server.Addr
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	l.listener = listener
+	l.served = make(chan error, 1)
+	go func() {
+		l.served <- l.server.Serve(listener)
+	}()
+	return nil
+}
+
+// Addr returns the address the server listens on, or nil if Start has not been called successfully.
+func (l *Lifecycle) Addr() net.Addr {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.listener == nil {
+		return nil
+	}
+	return l.listener.Addr()
+}
+
+// Stop shuts down the server and the Shutdowner with ShutdownWithServer and waits for the serving goroutine to return.
+// It returns the error of ShutdownWithServer, if any, or else the error of serving other than http.ErrServerClosed.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	served := l.served
+	l.mu.Unlock()
+	if served == nil {
+		return errors.New("shutdown: lifecycle not started")
+	}
+
+	err := l.g.ShutdownWithServer(ctx, l.server)
+	if err != nil {
+		return err
+	}
+	if serveErr := <-served; !errors.Is(serveErr, http.ErrServerClosed) {
+		return serveErr
+	}
+	return nil
+}
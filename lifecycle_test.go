@@ -0,0 +1,52 @@
+package shutdown_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestLifecycle(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+	server := &http.Server{Handler: shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	}))}
+	lifecycle := shutdown.NewLifecycle(server, &shutdowner)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := lifecycle.Stop(ctx); err == nil {
+		t.Errorf("expected an error when stopping a lifecycle that has not been started")
+	}
+	if err := lifecycle.Start(ctx); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	if err := lifecycle.Start(ctx); err == nil {
+		t.Errorf("expected an error when starting a lifecycle twice")
+	}
+
+	resp, err := http.Get("http://" + lifecycle.Addr().String())
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello" {
+		t.Errorf("expected body %q, got %q", "hello", body)
+	}
+
+	if err := lifecycle.Stop(ctx); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+	if !shutdowner.IsDrained() {
+		t.Errorf("expected the shutdowner to be drained")
+	}
+	if _, err := http.Get("http://" + lifecycle.Addr().String()); err == nil {
+		t.Errorf("expected the server to be stopped")
+	}
+}
@@ -0,0 +1,191 @@
+package shutdown
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// WithLogger configures the logger the Shutdowner reports the progress of the shutdown to: the begin of the shutdown, a
+// debug line with the remaining count for each handler finishing during the drain, and the outcome of the drain. By
+// default, nothing is logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(g *Shutdowner) {
+		g.cfg.logger = logger
+	}
+}
+
+// WithStdLogger configures a classic logger, e.g. the ErrorLog of the http.Server, that the progress of the shutdown is
+// reported to if no logger is configured with WithLogger. The messages are formatted like those of slog.TextHandler
+// without the time, which the logger adds according to its flags. Debug messages are not logged.
+func WithStdLogger(logger *log.Logger) Option {
+	return func(g *Shutdowner) {
+		g.cfg.stdLogger = slog.New(slog.NewTextHandler(stdLogWriter{logger}, &slog.HandlerOptions{
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && a.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return a
+			},
+		}))
+	}
+}
+
+// stdLogWriter writes the lines formatted by a slog.TextHandler to a log.Logger.
+type stdLogWriter struct {
+	logger *log.Logger
+}
+
+func (w stdLogWriter) Write(p []byte) (int, error) {
+	w.logger.Print(string(bytes.TrimSuffix(p, []byte("\n"))))
+	return len(p), nil
+}
+
+// logger returns the configured logger, if any.
+func (g *Shutdowner) logger() *slog.Logger {
+	if g.cfg.logger != nil {
+		return g.cfg.logger
+	}
+	return g.cfg.stdLogger
+}
+
+// log logs the message with the configured logger, if any.
+func (g *Shutdowner) log(level slog.Level, msg string, args ...any) {
+	logger := g.logger()
+	if logger == nil {
+		return
+	}
+	if g.cfg.name != "" {
+		args = append(args, slog.String("shutdowner", g.cfg.name))
+	}
+	logger.Log(context.Background(), level, msg, args...)
+}
+
+// WithDrainRequestLog configures whether each request that completes or is rejected after shutdown began is logged at
+// debug level with its method, path, outcome and age, giving an audit trail of what happened during the deploy window,
+// in addition to the line logged for each finishing handler. It requires a logger configured with WithLogger and costs
+// nothing when disabled.
+func WithDrainRequestLog(enabled bool) Option {
+	return func(g *Shutdowner) {
+		g.cfg.drainRequestLog = enabled
+	}
+}
+
+// drainRequestLogEnabled reports whether requests are logged during drain.
+func (g *Shutdowner) drainRequestLogEnabled() bool {
+	return g.cfg.drainRequestLog && g.cfg.logger != nil
+}
+
+// logDrainRequest logs a request that completed or was rejected with the given outcome, if shutdown has begun.
+func (g *Shutdowner) logDrainRequest(r *http.Request, outcome string, started time.Time) {
+	if !g.shuttingDown.Load() {
+		return
+	}
+	g.log(slog.LevelDebug, "request during drain", slog.String("method", r.Method), slog.String("path", r.URL.Path),
+		slog.String("outcome", outcome), slog.Duration("age", time.Since(started)))
+}
+
+// LoggingMiddleware returns a middleware that wraps handlers with Middleware and additionally logs the completion of
+// each request at info level with its method, path, status and duration. Requests completing after shutdown began are
+// annotated with during_drain=true. The tracking is the outermost layer, so Validate accepts handlers wrapped with it.
+func (g *Shutdowner) LoggingMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", sw.statusCode()),
+				slog.Duration("duration", time.Since(started)),
+			}
+			if g.shuttingDown.Load() {
+				attrs = append(attrs, slog.Bool("during_drain", true))
+			}
+			if g.cfg.name != "" {
+				attrs = append(attrs, slog.String("shutdowner", g.cfg.name))
+			}
+			logger.LogAttrs(r.Context(), slog.LevelInfo, "request completed", attrs...)
+		}))
+	}
+}
+
+// statusWriter records the status code of a response for LoggingMiddleware.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// statusCode returns the recorded status code, which is 200 OK if the handler did not write anything.
+func (w *statusWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// WithSummaryLog makes the Shutdowner log a single line summarizing the drain to the configured logger once it
+// completed or timed out for the first time, with its duration, the handlers active at the start and remaining, the
+// requests rejected since shutdown began and the outcome, i.e. drained, timed out or cancelled. It is logged in
+// addition to the other messages, so that a deploy can be judged from a single line.
+func WithSummaryLog() Option {
+	return func(g *Shutdowner) {
+		g.cfg.summaryLog = true
+	}
+}
+
+// logSummary logs the line configured with WithSummaryLog for the first drain reported by r.
+func (g *Shutdowner) logSummary(r Report, rejected int64) {
+	if !g.cfg.summaryLog || !g.summaryLogged.CompareAndSwap(false, true) {
+		return
+	}
+	outcome, level := "drained", slog.LevelInfo
+	var cancelled *DrainCancelledError
+	switch {
+	case errors.As(r.Err, &cancelled):
+		outcome, level = "cancelled", slog.LevelWarn
+	case r.Err != nil:
+		outcome, level = "timed out", slog.LevelWarn
+	}
+	g.log(level, "shutdown summary", slog.Duration("duration", r.Duration),
+		slog.Int64("active_at_start", r.ActiveAtStart), slog.Int64("remaining", r.Remaining),
+		slog.Int64("rejected", rejected), slog.String("outcome", outcome))
+}
+
+// logHandlerFinished logs a handler that finished during the drain at debug level, if a logger is configured and
+// enabled for it.
+func (g *Shutdowner) logHandlerFinished(remaining int64) {
+	if !g.shuttingDown.Load() {
+		return
+	}
+	logger := g.logger()
+	if logger == nil || !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	g.log(slog.LevelDebug, "handler finished during drain", slog.Int64("remaining", remaining))
+}
@@ -0,0 +1,252 @@
+package shutdown_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestWithDrainRequestLog(t *testing.T) {
+	t.Parallel()
+	var logs bytes.Buffer
+	shutdowner := shutdown.NewShutdowner(
+		shutdown.WithLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		shutdown.WithDrainRequestLog(true),
+		shutdown.WithRejectUpgradesOnly(),
+	)
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// requests before shutdown are not logged
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/before", nil))
+
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/served", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), newUpgradeRequest())
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, "request during drain") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 request log lines, got %d: %s", len(lines), logs.String())
+	}
+	for i, want := range []string{"method=POST path=/served outcome=served", "method=GET path=/ws outcome=rejected"} {
+		if !strings.Contains(lines[i], want) || !strings.Contains(lines[i], "level=DEBUG") {
+			t.Errorf("expected log line %q to contain %q at debug level", lines[i], want)
+		}
+	}
+}
+
+func TestWithDrainRequestLog_Disabled(t *testing.T) {
+	t.Parallel()
+	var logs bytes.Buffer
+	shutdowner := shutdown.NewShutdowner(
+		shutdown.WithLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		shutdown.WithDrainRequestLog(false),
+	)
+	_ = shutdowner.BeginShutdown()
+	shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if strings.Contains(logs.String(), "request during drain") {
+		t.Errorf("expected no request log lines, got %s", logs.String())
+	}
+}
+
+func TestShutdowner_LoggingMiddleware(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	handler := shutdowner.LoggingMiddleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(entered)
+			<-release
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	if err := shutdowner.Validate(handler); err != nil {
+		t.Errorf("expected the tracking to be the outermost layer, got %v", err)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/before", nil))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	}()
+	<-entered
+	if got := shutdowner.ActiveCount(); got != 1 {
+		t.Errorf("expected 1 tracked handler, got %d", got)
+	}
+
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	close(release)
+	<-done
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %q", lines)
+	}
+	for i, want := range []string{"method=POST path=/before status=201", "method=GET path=/slow status=201"} {
+		if !strings.Contains(lines[i], `msg="request completed" `+want) {
+			t.Errorf("expected log line %d to contain %q, got %q", i, want, lines[i])
+		}
+	}
+	if strings.Contains(lines[0], "during_drain") || !strings.Contains(lines[1], "during_drain=true") {
+		t.Errorf("expected only the request completing during drain to be annotated, got %q", lines)
+	}
+}
+
+func TestWithStdLogger(t *testing.T) {
+	t.Parallel()
+	var logs bytes.Buffer
+	shutdowner := shutdown.NewShutdowner(shutdown.WithStdLogger(log.New(&logs, "http: ", 0)), shutdown.WithName("api"))
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	want := []string{
+		`http: level=INFO msg="shutdown began" active=0 cause="shutdown: shutting down" shutdowner=api`,
+		`http: level=INFO msg="drain completed"`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d log lines, got %q", len(want), lines)
+	}
+	for i := range want {
+		if !strings.HasPrefix(lines[i], want[i]) {
+			t.Errorf("expected log line %q to start with %q", lines[i], want[i])
+		}
+	}
+}
+
+func TestWithStdLogger_SlogLoggerPreferred(t *testing.T) {
+	t.Parallel()
+	var std, structured bytes.Buffer
+	shutdowner := shutdown.NewShutdowner(
+		shutdown.WithStdLogger(log.New(&std, "", 0)),
+		shutdown.WithLogger(slog.New(slog.NewTextHandler(&structured, nil))),
+	)
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if std.Len() != 0 || !strings.Contains(structured.String(), "shutdown began") {
+		t.Errorf("expected only the slog logger to be used, got %q and %q", std.String(), structured.String())
+	}
+}
+
+func TestWithSummaryLog(t *testing.T) {
+	t.Parallel()
+	var logs bytes.Buffer
+	shutdowner := shutdown.NewShutdowner(
+		shutdown.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		shutdown.WithSummaryLog(),
+		shutdown.WithRejectNewRequests(),
+	)
+	entered, release := make(chan struct{}), make(chan struct{})
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}))
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ws", nil))
+	<-entered
+
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/late", nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err == nil {
+		t.Fatalf("expected the drain to time out")
+	}
+	close(release)
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	_ = shutdowner.Shutdown(context.Background())
+
+	var lines []string
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, "shutdown summary") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) != 1 {
+		t.Fatalf("expected a single summary line, got %d: %s", len(lines), logs.String())
+	}
+	for _, want := range []string{"level=WARN", "duration=", "active_at_start=1", "remaining=1", "rejected=1",
+		`outcome="timed out"`} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("expected summary line %q to contain %q", lines[0], want)
+		}
+	}
+}
+
+func TestWithLogger_HandlerFinishedDuringDrain(t *testing.T) {
+	t.Parallel()
+	var logs bytes.Buffer
+	shutdowner := shutdown.NewShutdowner(
+		shutdown.WithLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	const n = 2
+	entered, release := make(chan struct{}, n), make(chan struct{})
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ws" {
+			entered <- struct{}{}
+			<-release
+		}
+	}))
+	// handlers finishing before shutdown are not logged
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/before", nil))
+	for range n {
+		go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ws", nil))
+		<-entered
+	}
+
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	close(release)
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	var remaining []string
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, "handler finished during drain") {
+			if !strings.Contains(line, "level=DEBUG") {
+				t.Errorf("expected log line %q at debug level", line)
+			}
+			remaining = append(remaining, line[strings.Index(line, "remaining="):])
+		}
+	}
+	slices.Sort(remaining)
+	if want := []string{"remaining=0", "remaining=1"}; !slices.Equal(remaining, want) {
+		t.Errorf("expected a line per finished handler with %v, got %v: %s", want, remaining, logs.String())
+	}
+}
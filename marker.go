@@ -0,0 +1,39 @@
+package shutdown
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// WithShutdownMarkerFile configures the Shutdowner to create a file at path when shutdown begins and to remove it once
+// a Shutdown call observed the drain to complete, so that external tools like preStop hooks or health scrapers can
+// detect the drain through the filesystem. The file contains the time shutdown began in RFC 3339 format. If the drain
+// times out, the file is left in place. Errors writing or removing the file are logged, but don't affect the drain.
+func WithShutdownMarkerFile(path string) Option {
+	return func(g *Shutdowner) {
+		g.cfg.markerFile = path
+	}
+}
+
+// writeMarkerFile creates the marker file, if configured.
+func (g *Shutdowner) writeMarkerFile(startedAt time.Time) {
+	if g.cfg.markerFile == "" {
+		return
+	}
+	if err := os.WriteFile(g.cfg.markerFile, []byte(startedAt.Format(time.RFC3339)+"\n"), 0o644); err != nil {
+		g.log(slog.LevelWarn, "failed to write shutdown marker file", slog.Any("error", err))
+	}
+}
+
+// removeMarkerFile removes the marker file, if configured.
+func (g *Shutdowner) removeMarkerFile() {
+	if g.cfg.markerFile == "" {
+		return
+	}
+	if err := os.Remove(g.cfg.markerFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		g.log(slog.LevelWarn, "failed to remove shutdown marker file", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,80 @@
+package shutdown_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestWithShutdownMarkerFile(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "draining")
+	shutdowner := shutdown.NewShutdowner(shutdown.WithShutdownMarkerFile(path))
+
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(entered)
+			<-release
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	<-entered
+
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected no marker file before shutdown, got %v", err)
+	}
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected the marker file to exist during the drain, got %v", err)
+	}
+	startedAt, _ := shutdowner.ShutdownStartedAt()
+	if got, want := strings.TrimSpace(string(content)), startedAt.Format(time.RFC3339); got != want {
+		t.Errorf("expected the marker file to contain %q, got %q", want, got)
+	}
+
+	close(release)
+	<-done
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the marker file to be removed after the drain, got %v", err)
+	}
+}
+
+func TestWithShutdownMarkerFile_WriteError(t *testing.T) {
+	t.Parallel()
+	var logs bytes.Buffer
+	shutdowner := shutdown.NewShutdowner(
+		shutdown.WithShutdownMarkerFile(filepath.Join(t.TempDir(), "missing", "draining")),
+		shutdown.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Errorf("expected marker file errors not to affect the drain, got %v", err)
+	}
+	if !strings.Contains(logs.String(), "failed to write shutdown marker file") {
+		t.Errorf("expected the error to be logged, got %q", logs.String())
+	}
+}
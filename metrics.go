@@ -0,0 +1,26 @@
+package shutdown
+
+import "time"
+
+// Metrics receives the measurements of a Shutdowner, e.g. to adapt them to Prometheus, OpenTelemetry or statsd.
+// Implementations must be safe for concurrent use, and since the handler methods are called on every request, they
+// should be cheap.
+type Metrics interface {
+	// HandlerStarted is called when a handler wrapped with Middleware starts, or a background task started with
+	// GoNamed, Go or Track, or a sub-operation registered with SubContext.
+	HandlerStarted()
+	// HandlerFinished is called when a handler reported by HandlerStarted returns.
+	HandlerFinished()
+	// RequestRejected is called when the middleware rejects a request because shutdown began.
+	RequestRejected()
+	// DrainCompleted is called when a Shutdown call returns after waiting for the drain, with the time since shutdown
+	// began and the number of handlers that were still active, which is zero unless the drain timed out.
+	DrainCompleted(d time.Duration, remaining int)
+}
+
+// WithMetrics configures the Metrics the Shutdowner reports to. By default, nothing is reported.
+func WithMetrics(m Metrics) Option {
+	return func(g *Shutdowner) {
+		g.cfg.metrics = m
+	}
+}
@@ -0,0 +1,75 @@
+package shutdown_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+// recordingMetrics records the calls of the Metrics methods in order.
+type recordingMetrics struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (m *recordingMetrics) record(call string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, call)
+}
+
+func (m *recordingMetrics) HandlerStarted()  { m.record("started") }
+func (m *recordingMetrics) HandlerFinished() { m.record("finished") }
+func (m *recordingMetrics) RequestRejected() { m.record("rejected") }
+func (m *recordingMetrics) DrainCompleted(d time.Duration, remaining int) {
+	m.record(fmt.Sprintf("drained remaining=%d", remaining))
+}
+
+func TestWithMetrics(t *testing.T) {
+	t.Parallel()
+	metrics := &recordingMetrics{}
+	shutdowner := shutdown.NewShutdowner(shutdown.WithMetrics(metrics), shutdown.WithRejectNewRequests())
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ws" {
+			close(entered)
+			<-release
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ws", nil))
+	}()
+	<-entered
+
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/late", nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err == nil {
+		t.Fatalf("expected the drain to time out")
+	}
+	close(release)
+	<-served
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	want := []string{"started", "finished", "started", "rejected", "drained remaining=1", "finished",
+		"drained remaining=0"}
+	if !slices.Equal(metrics.calls, want) {
+		t.Errorf("expected calls %v, got %v", want, metrics.calls)
+	}
+}
@@ -0,0 +1,151 @@
+package shutdown
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Option configures a Shutdowner created with NewShutdowner.
+type Option func(*Shutdowner)
+
+// config holds the settings applied by options. The zero value is the configuration of a zero value Shutdowner.
+type config struct {
+	name            string
+	logger          *slog.Logger
+	stdLogger       *slog.Logger
+	drainRequestLog bool
+
+	webhookURL    string
+	webhookClient *http.Client
+
+	migrationTarget func() string
+
+	disableKeepAlive       bool
+	hijackTracking         bool
+	countFromFirstWrite    bool
+	grace                  func(r *http.Request) time.Duration
+	maxHandlerDuration     time.Duration
+	recover                func(w http.ResponseWriter, r *http.Request, v any)
+	summaryLog             bool
+	metrics                Metrics
+	drainTimeout           time.Duration
+	rejectionHandler       http.Handler
+	maxSpawn               int
+	strict                 bool
+	workerCtx              context.Context
+	countProgressEvery     int64
+	countProgress          func(remaining int64)
+	drainProgressInterval  time.Duration
+	drainProgress          func(remaining int64)
+	pprofLabels            bool
+	forceCloseOnTimeout    bool
+	waitForConns           bool
+	limitBody              bool
+	maxBody                int64
+	sequentialHooks        bool
+	dedupeHooks            bool
+	drainPredicates        []func(r *http.Request) bool
+	pollInterval           time.Duration
+	connectionCloseOnDrain bool
+	shutdownConcurrency    int
+	skipCancelled          bool
+	preserveBelow          int64
+	systemdNotify          bool
+	sampling               bool
+	sampleRate             float64
+	markerFile             string
+
+	quiescence time.Duration
+	guard      func() error
+
+	tracking            bool
+	contextInjection    bool
+	contextCancellation bool
+
+	classify func(r *http.Request) string
+
+	reject        rejectPolicy
+	upgradeReject func(w http.ResponseWriter, r *http.Request)
+}
+
+// NewShutdowner creates a Shutdowner configured with the given options. The zero value of Shutdowner is ready to use as
+// well and behaves like a Shutdowner created without any options: all options are optional, and their defaults are
+// documented with each of them. In particular, nothing is logged without WithLogger, the drain is only bounded by the
+// context passed to Shutdown without WithDrainTimeout, and rejected requests are answered with a plain error response
+// without WithRejectionHandler.
+func NewShutdowner(opts ...Option) *Shutdowner {
+	g := &Shutdowner{}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// WithSkipCancelledRequests configures the middleware to drop requests whose context is already done when they reach
+// it, e.g. because the client went away, without invoking the wrapped handler or counting them, since serving them is
+// pointless.
+func WithSkipCancelledRequests() Option {
+	return func(g *Shutdowner) {
+		g.cfg.skipCancelled = true
+	}
+}
+
+// WithDrainTimeout configures the default maximum duration of the drain, which applies if the context has no deadline
+// to Shutdown, ShutdownWithServer, ShutdownWithServers, ShutdownPhased, ShutdownWithDeadlines, ShutdownRateLimited,
+// ShutdownWithGRPC, ShutdownWithCallback, the graceful shutdown started by RunUntilSignalWithForce and Serve with a
+// timeout of zero or less. By default, the drain is only bounded by the context.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(g *Shutdowner) {
+		g.cfg.drainTimeout = d
+	}
+}
+
+// WithRejectionHandler configures the handler that serves the requests rejected with 503 Service Unavailable by the
+// middleware after shutdown began, e.g. because of WithRejectUpgradesOnly or WithRejectNewRequests, instead of the
+// plain error response. It could add a Retry-After header or a JSON body, for instance, and is passed the original
+// request, so the response can vary by path. Rejected requests are not counted as active handlers. It is not used for
+// upgrade requests if WithUpgradeRejectResponse is configured.
+func WithRejectionHandler(h http.Handler) Option {
+	return func(g *Shutdowner) {
+		g.cfg.rejectionHandler = h
+	}
+}
+
+// withDrainTimeout returns ctx bounded by the timeout configured with WithDrainTimeout, unless ctx already has a
+// deadline.
+func (g *Shutdowner) withDrainTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || g.cfg.drainTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, g.cfg.drainTimeout)
+}
+
+// WithDrainPredicate configures a function that decides for each request, when it reaches the middleware, whether it
+// takes part in the drain at all, e.g. based on its headers or authentication. Requests for which it returns false are
+// passed through to the wrapped handler without being tracked, counted or rejected, so Shutdown does not wait for them.
+// If several predicates are configured, e.g. with WithSkipPaths, a request must satisfy all of them to take part.
+func WithDrainPredicate(participates func(r *http.Request) bool) Option {
+	return func(g *Shutdowner) {
+		g.cfg.drainPredicates = append(g.cfg.drainPredicates, participates)
+	}
+}
+
+// WithName configures the name of the Shutdowner, which is included in its String representation and helps telling
+// several instances apart in logs.
+func WithName(name string) Option {
+	return func(g *Shutdowner) {
+		g.cfg.name = name
+	}
+}
+
+// WithShutdownGuard configures a function that is consulted when shutdown is requested with Shutdown,
+// ShutdownWithServer, BeginShutdown or any other method beginning the shutdown. If it returns an error, the shutdown
+// does not begin and the error is returned to the caller, e.g. to refuse shutting down while a critical migration is
+// in progress. The guard is consulted again on every request until the shutdown begins.
+func WithShutdownGuard(guard func() error) Option {
+	return func(g *Shutdowner) {
+		g.cfg.guard = guard
+	}
+}
@@ -0,0 +1,134 @@
+package shutdown
+
+import (
+	"context"
+	"time"
+)
+
+// ShutdownPhased shuts down in two phases: first, it waits up to soft for the handlers to finish on their own, then it
+// cancels the request contexts of the remaining handlers with ErrGraceExpired and waits for them until ctx is done. It
+// returns nil if all handlers finished, and otherwise the error Shutdown returns. Cancelling the request contexts
+// requires WithContextInjection; with WithContextCancellation, the request contexts are already cancelled when shutdown
+// begins, so there is no soft phase for handlers watching their context.
+func (g *Shutdowner) ShutdownPhased(ctx context.Context, soft time.Duration) error {
+	ctx, cancel := g.withDrainTimeout(ctx)
+	defer cancel()
+
+	if err := g.begin(ctx, nil, true); err != nil {
+		return err
+	}
+	t := time.AfterFunc(soft, func() { g.cancelHandlers(ErrGraceExpired) })
+	defer t.Stop()
+	return g.Shutdown(ctx)
+}
+
+// handlerContext returns a context derived from parent that is registered for cancellation by ShutdownPhased, and a
+// function that must be called to unregister it when the handler returns.
+func (g *Shutdowner) handlerContext(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	id := g.nextID.Add(1)
+	g.handlerMu.Lock()
+	if g.handlersCancelled != nil {
+		// the handler started after the soft phase expired
+		cause := g.handlersCancelled
+		g.handlerMu.Unlock()
+		cancel(cause)
+		return ctx, func() {}
+	}
+	if g.handlerCancels == nil {
+		g.handlerCancels = make(map[uint64]context.CancelCauseFunc)
+	}
+	g.handlerCancels[id] = cancel
+	g.handlerMu.Unlock()
+	return ctx, func() {
+		g.handlerMu.Lock()
+		delete(g.handlerCancels, id)
+		g.handlerMu.Unlock()
+		cancel(context.Canceled)
+	}
+}
+
+// cancelHandlers cancels the contexts of all registered handlers, and of those starting later, with cause.
+func (g *Shutdowner) cancelHandlers(cause error) {
+	g.handlerMu.Lock()
+	defer g.handlerMu.Unlock()
+	g.handlersCancelled = cause
+	for id, cancel := range g.handlerCancels {
+		cancel(cause)
+		delete(g.handlerCancels, id)
+	}
+}
+
+// ShutdownWithDeadlines is ShutdownPhased with the soft phase given as cancelAfter and the hard deadline as the deadline
+// of ctx: the request contexts of the handlers still running after cancelAfter are cancelled with ErrGraceExpired, so
+// they get a chance to clean up, and the method returns once ctx is done at the latest. If handlers are still running
+// then, the returned error is a *DrainTimeoutError with their number. A cancelAfter that is not shorter than the time
+// left until the deadline of ctx is a misuse, see WithStrictMode, since the handlers would never be cancelled.
+func (g *Shutdowner) ShutdownWithDeadlines(ctx context.Context, cancelAfter time.Duration) error {
+	if d, ok := ctx.Deadline(); ok && cancelAfter >= time.Until(d) {
+		g.misuse("ShutdownWithDeadlines called with a cancellation after the hard deadline")
+	}
+	return g.ShutdownPhased(ctx, cancelAfter)
+}
+
+// ShutdownRateLimited is like ShutdownPhased without a soft phase, but cancels the request contexts of the handlers one
+// at a time at a rate of perSecond with ErrGraceExpired, instead of all at once, so that thousands of websocket handlers
+// reconnecting elsewhere or flushing their state do not stampede downstream services. It returns nil if all handlers
+// finished, and otherwise the error Shutdown returns once ctx is done, which also stops the cancellation. Like
+// ShutdownPhased, it requires WithContextInjection. A perSecond below one is a misuse, see WithStrictMode; one per
+// second is used otherwise.
+func (g *Shutdowner) ShutdownRateLimited(ctx context.Context, perSecond int) error {
+	if perSecond < 1 {
+		g.misuse("ShutdownRateLimited called with a rate below one per second")
+		perSecond = 1
+	}
+	ctx, cancel := g.withDrainTimeout(ctx)
+	defer cancel()
+
+	if err := g.begin(ctx, nil, true); err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go g.cancelHandlersAtRate(ctx, perSecond, stop)
+	return g.Shutdown(ctx)
+}
+
+// minCancelInterval is the shortest interval at which ShutdownRateLimited cancels handlers. Higher rates cancel several
+// handlers per interval.
+const minCancelInterval = time.Millisecond
+
+// cancelHandlersAtRate cancels the contexts of the registered handlers at a rate of perSecond until ctx is done or stop
+// is closed.
+func (g *Shutdowner) cancelHandlersAtRate(ctx context.Context, perSecond int, stop <-chan struct{}) {
+	interval, batch := time.Second/time.Duration(perSecond), 1
+	if interval < minCancelInterval {
+		interval, batch = minCancelInterval, perSecond/int(time.Second/minCancelInterval)
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			g.cancelSomeHandlers(batch, ErrGraceExpired)
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		}
+	}
+}
+
+// cancelSomeHandlers cancels the contexts of up to n registered handlers with cause.
+func (g *Shutdowner) cancelSomeHandlers(n int, cause error) {
+	g.handlerMu.Lock()
+	defer g.handlerMu.Unlock()
+	for id, cancel := range g.handlerCancels {
+		if n == 0 {
+			return
+		}
+		cancel(cause)
+		delete(g.handlerCancels, id)
+		n--
+	}
+}
@@ -0,0 +1,223 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_ShutdownPhased(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithContextInjection())
+
+	entered := make(chan struct{}, 2)
+	causes := make(chan error, 2)
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		if r.URL.Path == "/polite" {
+			// finishes within the soft phase
+			<-shutdowner.Done()
+			causes <- nil
+			return
+		}
+		<-r.Context().Done()
+		causes <- context.Cause(r.Context())
+	}))
+	for _, path := range []string{"/polite", "/stubborn"} {
+		go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		<-entered
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := shutdowner.ShutdownPhased(ctx, 20*time.Millisecond); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected the request contexts to be cancelled after the soft phase, but the drain took %v", elapsed)
+	}
+	if cause := <-causes; cause != nil {
+		t.Errorf("expected the first handler to finish on its own, got %v", cause)
+	}
+	if cause := <-causes; !errors.Is(cause, shutdown.ErrGraceExpired) {
+		t.Errorf("expected the remaining handler to be cancelled with %v, got %v", shutdown.ErrGraceExpired, cause)
+	}
+
+	// handlers starting after the soft phase are cancelled right away
+	var cause error
+	handler = shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cause = context.Cause(r.Context())
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/late", nil))
+	if !errors.Is(cause, shutdown.ErrGraceExpired) {
+		t.Errorf("expected a late request context to be cancelled with %v, got %v", shutdown.ErrGraceExpired, cause)
+	}
+}
+
+func TestShutdowner_ShutdownPhased_Timeout(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithContextInjection())
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	go shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release // ignores the cancellation
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := shutdowner.ShutdownPhased(ctx, 5*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestShutdowner_ShutdownWithDeadlines(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithContextInjection())
+
+	entered, release := make(chan struct{}, 2), make(chan struct{})
+	defer close(release)
+	cleanedUp := make(chan error, 1)
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		if r.URL.Path == "/cleanup" {
+			<-r.Context().Done()
+			cleanedUp <- context.Cause(r.Context())
+			return
+		}
+		<-release // ignores the cancellation
+	}))
+	for _, path := range []string{"/cleanup", "/stuck"} {
+		go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		<-entered
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := shutdowner.ShutdownWithDeadlines(ctx, 5*time.Millisecond)
+	var timeoutErr *shutdown.DrainTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Remaining != 1 {
+		t.Errorf("expected a drain timeout with a single remaining handler, got %v", err)
+	}
+	if cause := <-cleanedUp; !errors.Is(cause, shutdown.ErrGraceExpired) {
+		t.Errorf("expected the request context to be cancelled with %v, got %v", shutdown.ErrGraceExpired, cause)
+	}
+}
+
+func TestShutdowner_ShutdownWithDeadlines_Misuse(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithContextInjection(), shutdown.WithStrictMode())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer func() {
+		if v := recover(); v == nil {
+			t.Errorf("expected a panic for a cancellation after the hard deadline")
+		}
+	}()
+	_ = shutdowner.ShutdownWithDeadlines(ctx, 2*time.Second)
+}
+
+func TestShutdowner_ShutdownRateLimited(t *testing.T) {
+	t.Parallel()
+	const n, perSecond = 10, 100
+	shutdowner := shutdown.NewShutdowner(shutdown.WithContextInjection())
+
+	entered := make(chan struct{}, n)
+	cancelled := make(chan time.Time, n)
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-r.Context().Done()
+		if errors.Is(context.Cause(r.Context()), shutdown.ErrGraceExpired) {
+			cancelled <- time.Now()
+		}
+	}))
+	for range n {
+		go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ws", nil))
+		<-entered
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := shutdowner.ShutdownRateLimited(ctx, perSecond); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	elapsed := time.Since(start)
+	if want := n * time.Second / perSecond; elapsed < want-10*time.Millisecond || elapsed > want+500*time.Millisecond {
+		t.Errorf("expected the handlers to be cancelled in about %v, took %v", want, elapsed)
+	}
+	close(cancelled)
+	var at []time.Time
+	for c := range cancelled {
+		at = append(at, c)
+	}
+	if len(at) != n {
+		t.Fatalf("expected %d handlers to be cancelled with %v, got %d", n, shutdown.ErrGraceExpired, len(at))
+	}
+	if spread := at[len(at)-1].Sub(at[0]); spread < (n-2)*time.Second/perSecond {
+		t.Errorf("expected the cancellations to be spread out, but they took %v", spread)
+	}
+}
+
+func TestShutdowner_ShutdownRateLimited_Cancelled(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithContextInjection())
+
+	entered, release := make(chan struct{}, 2), make(chan struct{})
+	defer close(release)
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	for range 2 {
+		go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ws", nil))
+		<-entered
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// the context is done before the first handler is due to be cancelled
+	err := shutdowner.ShutdownRateLimited(ctx, 1)
+	var timeoutErr *shutdown.DrainTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Remaining != 2 {
+		t.Errorf("expected a drain timeout with 2 remaining handlers, got %v", err)
+	}
+}
+
+func TestShutdowner_ShutdownRateLimited_HugeRate(t *testing.T) {
+	t.Parallel()
+	const n = 50
+	shutdowner := shutdown.NewShutdowner(shutdown.WithContextInjection())
+
+	entered := make(chan struct{}, n)
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-r.Context().Done()
+	}))
+	for range n {
+		go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ws", nil))
+		<-entered
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := shutdowner.ShutdownRateLimited(ctx, 2_000_000_000); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the handlers to be cancelled right away, took %v", elapsed)
+	}
+}
@@ -0,0 +1,45 @@
+package shutdown
+
+import (
+	"context"
+	"time"
+)
+
+// defaultPollInterval is the interval at which the drain state is checked by the variants of the drain that need to
+// poll, if no interval is configured with WithPollInterval.
+const defaultPollInterval = 5 * time.Millisecond
+
+// WithPollInterval configures the interval at which the drain state is checked by the variants of the drain that need
+// to poll, e.g. while waiting for quiescence. The plain Shutdown does not poll, but waits for the last handler to
+// signal its return. Shorter intervals detect the end of the drain earlier at the cost of more wakeups. The default is
+// 5ms.
+func WithPollInterval(d time.Duration) Option {
+	return func(g *Shutdowner) {
+		g.cfg.pollInterval = d
+	}
+}
+
+// pollInterval returns the configured poll interval.
+func (g *Shutdowner) pollInterval() time.Duration {
+	if g.cfg.pollInterval > 0 {
+		return g.cfg.pollInterval
+	}
+	return defaultPollInterval
+}
+
+// poll calls done immediately and then once per poll interval until it returns true, or until the drain is abandoned or
+// ctx is done.
+func (g *Shutdowner) poll(ctx context.Context, abandoned <-chan struct{}, done func() bool) error {
+	ticker := time.NewTicker(g.pollInterval())
+	defer ticker.Stop()
+	for !done() {
+		select {
+		case <-ticker.C:
+		case <-abandoned:
+			return ErrAborted
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package shutdown
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+)
+
+// WithPprofLabels configures the middleware to run the wrapped handlers with the pprof labels "method", "path" and
+// "drain_id", a number identifying the request within the Shutdowner. Goroutine profiles taken during a stuck drain then
+// show which handlers are stuck. Setting the labels costs a few allocations per request, so it is disabled by default.
+func WithPprofLabels() Option {
+	return func(g *Shutdowner) {
+		g.cfg.pprofLabels = true
+	}
+}
+
+// serveWithLabels serves the request with next under the pprof labels of the request.
+func (g *Shutdowner) serveWithLabels(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	labels := pprof.Labels(
+		"method", r.Method,
+		"path", r.URL.Path,
+		"drain_id", strconv.FormatUint(g.nextID.Add(1), 10),
+	)
+	pprof.Do(r.Context(), labels, func(ctx context.Context) {
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// StuckHandlersHandler returns a handler for an admin endpoint that responds with the goroutine stacks of the handlers
+// running with the pprof labels of WithPprofLabels, so that operators can see where handlers are blocked during a stuck
+// drain. The stacks are grouped like in a goroutine profile with debug=1, including the labels identifying the
+// requests. Like the probe handlers, the handler is never tracked by Middleware. It reveals internals of the
+// application, so it must not be exposed publicly.
+func (g *Shutdowner) StuckHandlersHandler() http.Handler {
+	return stuckHandlersHandler{g: g}
+}
+
+// stuckHandlersHandler is the handler returned by StuckHandlersHandler.
+type stuckHandlersHandler struct {
+	g *Shutdowner
+}
+
+func (stuckHandlersHandler) untracked() {}
+
+func (h stuckHandlersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var profile bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "shutting down: %t, active handlers: %d\n", h.g.shuttingDown.Load(), h.g.active.Load())
+	if !h.g.cfg.pprofLabels {
+		fmt.Fprintln(w, "pprof labels are not enabled, see WithPprofLabels")
+	}
+	// the profile consists of a header and blank line separated groups of goroutines
+	for _, group := range strings.Split(profile.String(), "\n\n") {
+		if strings.Contains(group, "# labels: {") && strings.Contains(group, `"drain_id":`) {
+			fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(group))
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package shutdown_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestWithPprofLabels(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		opts []shutdown.Option
+		want map[string]bool
+	}{
+		{name: "disabled", want: map[string]bool{}},
+		{
+			name: "enabled",
+			opts: []shutdown.Option{shutdown.WithPprofLabels()},
+			want: map[string]bool{"method=POST": true, "path=/stuck": true, "drain_id=1": true},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			shutdowner := shutdown.NewShutdowner(tc.opts...)
+
+			got := map[string]bool{}
+			shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				pprof.ForLabels(r.Context(), func(key, value string) bool {
+					got[key+"="+value] = true
+					return true
+				})
+			})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/stuck", nil))
+
+			if len(got) != len(tc.want) {
+				t.Errorf("expected labels %v, got %v", tc.want, got)
+			}
+			for l := range tc.want {
+				if !got[l] {
+					t.Errorf("expected label %s, got %v", l, got)
+				}
+			}
+		})
+	}
+}
+
+func TestShutdowner_StuckHandlersHandler(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithPprofLabels())
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	go shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		blockInStuckHandler(release)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/stuck/upload", nil))
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err == nil {
+		t.Fatalf("expected the drain to be stuck")
+	}
+
+	rec := httptest.NewRecorder()
+	shutdowner.Middleware(shutdowner.StuckHandlersHandler()).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/stuck", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"shutting down: true, active handlers: 1",
+		`"path":"/stuck/upload"`,
+		`"method":"PUT"`,
+		"blockInStuckHandler",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the response to contain %q, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "/debug/stuck") {
+		t.Errorf("expected the handler not to be tracked itself, got:\n%s", body)
+	}
+}
+
+// blockInStuckHandler blocks until release is closed. It is a separate function so that it shows up in the stacks.
+//
+//go:noinline
+func blockInStuckHandler(release <-chan struct{}) {
+	<-release
+}
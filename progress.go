@@ -0,0 +1,149 @@
+package shutdown
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// completionListener queues the remaining counts reported by the handlers finishing during ShutdownWithCallback.
+type completionListener struct {
+	mu     sync.Mutex
+	queue  []int64
+	signal chan struct{}
+}
+
+// WithCountProgress configures fn to be called each time the number of active handlers drops to a multiple of every
+// after shutdown began, e.g. at 1000, 900, 800 and so on, down to zero, which gives progress anchored to the count
+// instead of the time for drains of many connections. fn is called by the finishing handler, so it must be fast, and
+// it may be called concurrently by several handlers.
+func WithCountProgress(every int64, fn func(remaining int64)) Option {
+	return func(g *Shutdowner) {
+		g.cfg.countProgressEvery = every
+		g.cfg.countProgress = fn
+	}
+}
+
+// notifyCountProgress calls the function configured with WithCountProgress if remaining is one of its thresholds.
+func (g *Shutdowner) notifyCountProgress(remaining int64) {
+	if g.cfg.countProgress == nil || g.cfg.countProgressEvery <= 0 || remaining%g.cfg.countProgressEvery != 0 ||
+		!g.shuttingDown.Load() {
+		return
+	}
+	g.cfg.countProgress(remaining)
+}
+
+// WithDrainProgress configures fn to be called every interval with the number of active handlers while Shutdown waits
+// for them, e.g. to log that the drain is still waiting on them. Each waiting Shutdown call reports the progress, and
+// fn is not called anymore once the call returns. A zero interval disables it.
+func WithDrainProgress(interval time.Duration, fn func(remaining int64)) Option {
+	return func(g *Shutdowner) {
+		g.cfg.drainProgressInterval = interval
+		g.cfg.drainProgress = fn
+	}
+}
+
+// reportDrainProgress calls the function configured with WithDrainProgress every interval until done is closed or the
+// returned function is called, which waits for the last call to return.
+func (g *Shutdowner) reportDrainProgress(done <-chan struct{}) (stop func()) {
+	if g.cfg.drainProgress == nil || g.cfg.drainProgressInterval <= 0 {
+		return func() {}
+	}
+	stopped, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(g.cfg.drainProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				g.cfg.drainProgress(g.active.Load())
+			case <-done:
+				return
+			case <-stopped:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stopped)
+		<-exited
+	}
+}
+
+// ShutdownWithCallback is like Shutdown, but calls onEach with the number of remaining active handlers each time a
+// handler finishes during the drain, giving fine-grained progress without polling. onEach is called sequentially from
+// a dedicated goroutine, so a slow callback never blocks the finishing handlers, and all calls have returned by the
+// time ShutdownWithCallback returns. If ctx has no deadline, the drain is bounded by the timeout configured with
+// WithDrainTimeout, if any.
+func (g *Shutdowner) ShutdownWithCallback(ctx context.Context, onEach func(remaining int64)) error {
+	ctx, cancel := g.withDrainTimeout(ctx)
+	defer cancel()
+
+	if err := g.begin(ctx, nil, true); err != nil {
+		return err
+	}
+
+	l := &completionListener{signal: make(chan struct{}, 1)}
+	g.completionMu.Lock()
+	if g.completions == nil {
+		g.completions = make(map[*completionListener]struct{})
+	}
+	g.completions[l] = struct{}{}
+	g.completionN.Add(1)
+	g.completionMu.Unlock()
+
+	stop := make(chan struct{})
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		for {
+			select {
+			case <-l.signal:
+				l.dispatch(onEach)
+			case <-stop:
+				l.dispatch(onEach)
+				return
+			}
+		}
+	}()
+
+	err := g.Shutdown(ctx)
+
+	g.completionMu.Lock()
+	delete(g.completions, l)
+	g.completionN.Add(-1)
+	g.completionMu.Unlock()
+	close(stop)
+	<-dispatched
+	return err
+}
+
+// dispatch calls onEach for the queued counts.
+func (l *completionListener) dispatch(onEach func(remaining int64)) {
+	l.mu.Lock()
+	queue := l.queue
+	l.queue = nil
+	l.mu.Unlock()
+	for _, remaining := range queue {
+		onEach(remaining)
+	}
+}
+
+// notifyCompletion queues the remaining count for the listeners of ShutdownWithCallback, if any.
+func (g *Shutdowner) notifyCompletion(remaining int64) {
+	if g.completionN.Load() == 0 {
+		return
+	}
+	g.completionMu.Lock()
+	defer g.completionMu.Unlock()
+	for l := range g.completions {
+		l.mu.Lock()
+		l.queue = append(l.queue, remaining)
+		l.mu.Unlock()
+		select {
+		case l.signal <- struct{}{}:
+		default:
+		}
+	}
+}
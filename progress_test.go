@@ -0,0 +1,136 @@
+package shutdown_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_ShutdownWithCallback(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	const handlers = 3
+	entered := make(chan struct{}, handlers)
+	release := make(chan struct{})
+	for range handlers {
+		go shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entered <- struct{}{}
+			<-release
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	for range handlers {
+		<-entered
+	}
+	// handlers finishing before the drain are not reported
+	shutdowner.Middleware(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	go func() {
+		for range handlers {
+			time.Sleep(5 * time.Millisecond)
+			release <- struct{}{}
+		}
+	}()
+
+	var got []int64
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.ShutdownWithCallback(ctx, func(remaining int64) {
+		got = append(got, remaining)
+	}); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if want := []int64{2, 1, 0}; !slices.Equal(got, want) {
+		t.Errorf("expected the callback to report %v, got %v", want, got)
+	}
+}
+
+func TestWithCountProgress(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var thresholds []int64
+	shutdowner := shutdown.NewShutdowner(shutdown.WithCountProgress(100, func(remaining int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		thresholds = append(thresholds, remaining)
+	}))
+
+	const handlers = 350
+	var entered sync.WaitGroup
+	release := make(chan struct{})
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered.Done()
+		<-release
+	}))
+	// the handlers finishing before shutdown began don't report progress
+	for range 50 + handlers {
+		entered.Add(1)
+		go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	entered.Wait()
+	for range 50 {
+		release <- struct{}{}
+	}
+	for shutdowner.ActiveCount() != handlers {
+		time.Sleep(time.Millisecond)
+	}
+
+	_ = shutdowner.BeginShutdown()
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	slices.Sort(thresholds)
+	if want := []int64{0, 100, 200, 300}; !slices.Equal(thresholds, want) {
+		t.Errorf("expected progress at %v, got %v", want, thresholds)
+	}
+}
+
+func TestWithDrainProgress(t *testing.T) {
+	t.Parallel()
+	reports := make(chan int64, 100)
+	shutdowner := shutdown.NewShutdowner(shutdown.WithDrainProgress(2*time.Millisecond, func(remaining int64) {
+		reports <- remaining
+	}))
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	go shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	<-entered
+
+	shutdownDone := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		shutdownDone <- shutdowner.Shutdown(ctx)
+	}()
+	for range 3 {
+		if remaining := <-reports; remaining != 1 {
+			t.Errorf("expected progress with 1 remaining handler, got %d", remaining)
+		}
+	}
+	close(release)
+	if err := <-shutdownDone; err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	// no progress is reported once Shutdown returned
+	for len(reports) > 0 {
+		<-reports
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := len(reports); n != 0 {
+		t.Errorf("expected no progress after the drain, got %d reports", n)
+	}
+}
@@ -0,0 +1,33 @@
+package shutdown
+
+import (
+	"context"
+	"time"
+)
+
+// WithQuiescence configures Shutdown to only consider the drain complete once no handler has been active for the
+// duration d, instead of the first moment no handler is active. This absorbs trailing bursts of requests, e.g. from
+// keep-alive connections, that arrive after shutdown began. Every newly started handler restarts the quiet
+// period. Waiting for quiescence is bounded by the context passed to Shutdown, and the handlers are checked at the
+// interval configured with WithPollInterval.
+func WithQuiescence(d time.Duration) Option {
+	return func(g *Shutdowner) {
+		g.cfg.quiescence = d
+	}
+}
+
+// quiesce waits until no handler has been started or been active for the configured quiescence period.
+func (g *Shutdowner) quiesce(ctx context.Context, abandoned <-chan struct{}) error {
+	if g.cfg.quiescence <= 0 {
+		return nil
+	}
+
+	started, quietSince := g.started.Load(), time.Now()
+	return g.poll(ctx, abandoned, func() bool {
+		if s := g.started.Load(); s != started || g.active.Load() != 0 {
+			started, quietSince = s, time.Now()
+			return false
+		}
+		return time.Since(quietSince) >= g.cfg.quiescence
+	})
+}
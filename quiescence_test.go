@@ -0,0 +1,86 @@
+package shutdown_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestWithQuiescence(t *testing.T) {
+	t.Parallel()
+	const quiescence = 100 * time.Millisecond
+	shutdowner := shutdown.NewShutdowner(shutdown.WithQuiescence(quiescence))
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error)
+	start := time.Now()
+	go func() { done <- shutdowner.Shutdown(ctx) }()
+
+	// a late request arrives during the quiescence window and restarts it
+	time.Sleep(quiescence / 2)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	lateRequest := time.Now()
+
+	if err := <-done; err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if elapsed := time.Since(lateRequest); elapsed < quiescence {
+		t.Errorf("expected drain to complete at least %v after the late request, got %v", quiescence, elapsed)
+	}
+	if elapsed := time.Since(start); elapsed < quiescence*3/2 {
+		t.Errorf("expected the late request to restart the quiescence window, drain took %v", elapsed)
+	}
+}
+
+func TestWithQuiescence_Timeout(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithQuiescence(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err == nil {
+		t.Errorf("expected the context to bound waiting for quiescence")
+	}
+	if shutdowner.IsDrained() {
+		t.Errorf("expected shutdowner not to be drained")
+	}
+}
+
+func TestWithPollInterval(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name         string
+		pollInterval time.Duration
+		min, max     time.Duration
+	}{
+		{name: "default", min: 5 * time.Millisecond, max: 90 * time.Millisecond},
+		{name: "slow", pollInterval: 100 * time.Millisecond, min: 100 * time.Millisecond, max: time.Second},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			shutdowner := shutdown.NewShutdowner(
+				shutdown.WithQuiescence(time.Millisecond),
+				shutdown.WithPollInterval(tc.pollInterval),
+			)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			start := time.Now()
+			if err := shutdowner.Shutdown(ctx); err != nil {
+				t.Fatalf("no error expected but got %v", err)
+			}
+			// the quiet period is only observed to be over at the second check
+			if elapsed := time.Since(start); elapsed < tc.min || elapsed > tc.max {
+				t.Errorf("expected the drain to take between %v and %v, took %v", tc.min, tc.max, elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package shutdown
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// WithRecover makes Middleware recover panics of the handlers and pass the recovered value to fn, which is responsible
+// for answering the request. A nil fn answers with 500 Internal Server Error. The handler is counted as finished either
+// way, just as without the option, where the panic propagates to the server. Panics with http.ErrAbortHandler are not
+// recovered, since they are how handlers abort a response on purpose.
+func WithRecover(fn func(w http.ResponseWriter, r *http.Request, v any)) Option {
+	return func(g *Shutdowner) {
+		if fn == nil {
+			fn = func(w http.ResponseWriter, r *http.Request, v any) {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}
+		g.cfg.recover = fn
+	}
+}
+
+// recoverPanic is deferred by Middleware to recover a panic of the handler serving r with the function configured with
+// WithRecover.
+func (g *Shutdowner) recoverPanic(w http.ResponseWriter, r *http.Request) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if v == http.ErrAbortHandler {
+		panic(v)
+	}
+	g.log(slog.LevelError, "handler panicked", slog.String("path", r.URL.Path), slog.Any("panic", v))
+	g.cfg.recover(w, r, v)
+}
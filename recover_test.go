@@ -0,0 +1,88 @@
+package shutdown_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestWithRecover(t *testing.T) {
+	t.Parallel()
+
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+
+	t.Run("callback", func(t *testing.T) {
+		t.Parallel()
+		var recovered any
+		shutdowner := shutdown.NewShutdowner(shutdown.WithRecover(func(w http.ResponseWriter, r *http.Request, v any) {
+			recovered = v
+			w.WriteHeader(http.StatusTeapot)
+		}))
+		rec := httptest.NewRecorder()
+		shutdowner.Middleware(panicking).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+		if recovered != "boom" {
+			t.Errorf("expected the callback to be passed the panic, got %v", recovered)
+		}
+		if rec.Code != http.StatusTeapot {
+			t.Errorf("expected status %d, got %d", http.StatusTeapot, rec.Code)
+		}
+		if got := shutdowner.ActiveCount(); got != 0 {
+			t.Errorf("expected no active handlers, got %d", got)
+		}
+		if err := shutdowner.Shutdown(context.Background()); err != nil {
+			t.Errorf("no error expected but got %v", err)
+		}
+	})
+
+	t.Run("default", func(t *testing.T) {
+		t.Parallel()
+		shutdowner := shutdown.NewShutdowner(shutdown.WithRecover(nil))
+		rec := httptest.NewRecorder()
+		shutdowner.Middleware(panicking).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+		if got := shutdowner.ActiveCount(); got != 0 {
+			t.Errorf("expected no active handlers, got %d", got)
+		}
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		t.Parallel()
+		var shutdowner shutdown.Shutdowner
+		func() {
+			defer func() {
+				if v := recover(); v != "boom" {
+					t.Errorf("expected the panic to propagate, got %v", v)
+				}
+			}()
+			shutdowner.Middleware(panicking).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+		if got := shutdowner.ActiveCount(); got != 0 {
+			t.Errorf("expected no active handlers, got %d", got)
+		}
+	})
+
+	t.Run("abort handler", func(t *testing.T) {
+		t.Parallel()
+		shutdowner := shutdown.NewShutdowner(shutdown.WithRecover(nil))
+		func() {
+			defer func() {
+				if v := recover(); v != http.ErrAbortHandler {
+					t.Errorf("expected %v to propagate, got %v", http.ErrAbortHandler, v)
+				}
+			}()
+			shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				panic(http.ErrAbortHandler)
+			})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+		if got := shutdowner.ActiveCount(); got != 0 {
+			t.Errorf("expected no active handlers, got %d", got)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package shutdown
+
+import (
+	"net/http"
+	"strings"
+)
+
+// rejectPolicy determines which requests arriving after shutdown began are rejected by the middleware.
+type rejectPolicy int
+
+const (
+	rejectNone rejectPolicy = iota
+	rejectUpgrades
+	rejectAll
+)
+
+// WithRejectUpgradesOnly configures the middleware to reject upgrade requests, e.g. websocket handshakes, that arrive
+// after shutdown began with 503 Service Unavailable, while other requests are still served. Use
+// WithUpgradeRejectResponse to customize the response.
+func WithRejectUpgradesOnly() Option {
+	return func(g *Shutdowner) {
+		g.cfg.reject = rejectUpgrades
+	}
+}
+
+// WithRejectNewRequests configures the middleware to reject all requests that arrive after shutdown began with 503
+// Service Unavailable instead of serving them, so that new requests, e.g. on kept-alive connections, cannot prolong
+// the drain. Requests already in flight are not affected. As shutdown begins before a load balancer notices the
+// failing readiness check, only use it if the traffic is not routed to the instance anymore by then.
+func WithRejectNewRequests() Option {
+	return func(g *Shutdowner) {
+		g.cfg.reject = rejectAll
+	}
+}
+
+// WithUpgradeRejectResponse configures the function that serves upgrade requests rejected because of
+// WithRejectUpgradesOnly or WithRejectNewRequests instead of the plain 503 Service Unavailable response, e.g. to
+// perform a minimal websocket handshake followed by a close frame for clients that require it.
+func WithUpgradeRejectResponse(f func(w http.ResponseWriter, r *http.Request)) Option {
+	return func(g *Shutdowner) {
+		g.cfg.upgradeReject = f
+	}
+}
+
+// WithMaxBodyDuringDrain configures the middleware to reject requests that arrive after shutdown began and declare a
+// Content-Length greater than n with 413 Request Entity Too Large, so that large uploads are not started right before
+// the application shuts down. Requests with an unknown length, e.g. chunked uploads, are not rejected.
+func WithMaxBodyDuringDrain(n int64) Option {
+	return func(g *Shutdowner) {
+		g.cfg.limitBody = true
+		g.cfg.maxBody = n
+	}
+}
+
+// reject serves the rejection response and returns true if r must be rejected according to the configured policy. It
+// must only be called after shutdown began. A custom upgrade rejection response is tracked like a handler, so Shutdown
+// waits for a handshake performed by it to complete.
+func (g *Shutdowner) reject(w http.ResponseWriter, r *http.Request) bool {
+	if g.cfg.limitBody && r.ContentLength > g.cfg.maxBody {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return true
+	}
+	switch g.cfg.reject {
+	case rejectUpgrades:
+		if !isUpgradeRequest(r) {
+			return false
+		}
+	case rejectAll:
+	default:
+		return false
+	}
+	if g.cfg.upgradeReject == nil || !isUpgradeRequest(r) {
+		g.serveRejection(w, r)
+		return true
+	}
+	g.wg.Add(1)
+	g.active.Add(1)
+	defer g.done()
+	defer g.active.Add(-1)
+	g.cfg.upgradeReject(w, r)
+	return true
+}
+
+// serveRejection serves a request rejected with 503 Service Unavailable.
+func (g *Shutdowner) serveRejection(w http.ResponseWriter, r *http.Request) {
+	if g.cfg.rejectionHandler != nil {
+		g.cfg.rejectionHandler.ServeHTTP(w, r)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
+
+// isUpgradeRequest reports whether r asks for a protocol upgrade, i.e. it has an Upgrade header and a Connection header
+// containing the "upgrade" token.
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			// only spaces and tabs are optional whitespace around list elements (RFC 9110, section 5.6.1)
+			if strings.EqualFold(strings.Trim(token, " \t"), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
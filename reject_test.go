@@ -0,0 +1,316 @@
+package shutdown_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func newUpgradeRequest() *http.Request {
+	r := httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	return r
+}
+
+func TestWithRejectUpgradesOnly(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name           string
+		opts           []shutdown.Option
+		request        func() *http.Request
+		expectedStatus int
+		expectServed   bool
+	}{
+		{
+			name:           "upgrade request rejected",
+			opts:           []shutdown.Option{shutdown.WithRejectUpgradesOnly()},
+			request:        newUpgradeRequest,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "upgrade request rejected with custom response",
+			opts: []shutdown.Option{
+				shutdown.WithRejectUpgradesOnly(),
+				shutdown.WithUpgradeRejectResponse(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusGone)
+				}),
+			},
+			request:        newUpgradeRequest,
+			expectedStatus: http.StatusGone,
+		},
+		{
+			name:           "plain request served",
+			opts:           []shutdown.Option{shutdown.WithRejectUpgradesOnly()},
+			request:        func() *http.Request { return httptest.NewRequest("GET", "/", nil) },
+			expectedStatus: http.StatusOK,
+			expectServed:   true,
+		},
+		{
+			name:           "upgrade request served without reject option",
+			request:        newUpgradeRequest,
+			expectedStatus: http.StatusOK,
+			expectServed:   true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			shutdowner := shutdown.NewShutdowner(tc.opts...)
+			var served bool
+			handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				served = true
+			}))
+			if err := shutdowner.Shutdown(context.Background()); err != nil {
+				t.Fatalf("no error expected but got %v", err)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, tc.request())
+			if rec.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, rec.Code)
+			}
+			if served != tc.expectServed {
+				t.Errorf("expected served to be %v, got %v", tc.expectServed, served)
+			}
+		})
+	}
+}
+
+func TestWithUpgradeRejectResponse_Tracked(t *testing.T) {
+	t.Parallel()
+	started, release := make(chan struct{}), make(chan struct{})
+	shutdowner := shutdown.NewShutdowner(
+		shutdown.WithRejectUpgradesOnly(),
+		shutdown.WithUpgradeRejectResponse(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release // e.g. a handshake followed by a close frame
+		}),
+	)
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	go handler.ServeHTTP(httptest.NewRecorder(), newUpgradeRequest())
+	<-started
+
+	if got := shutdowner.ActiveCount(); got != 1 {
+		t.Errorf("expected the reject response to be counted as active, got %d", got)
+	}
+	close(release)
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+}
+
+func TestWithMaxBodyDuringDrain(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithMaxBodyDuringDrain(10))
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	large := func() *http.Request {
+		return httptest.NewRequest("POST", "/upload", strings.NewReader(strings.Repeat("x", 11)))
+	}
+	chunked := func() *http.Request {
+		r := httptest.NewRequest("POST", "/upload", strings.NewReader(strings.Repeat("x", 11)))
+		r.ContentLength = -1
+		return r
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, large())
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected large requests to be served before shutdown, got %d", rec.Code)
+	}
+
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	tt := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{name: "small", req: httptest.NewRequest("POST", "/upload", strings.NewReader("small")), want: http.StatusNoContent},
+		{name: "at limit", req: httptest.NewRequest("POST", "/upload", strings.NewReader(strings.Repeat("x", 10))), want: http.StatusNoContent},
+		{name: "large", req: large(), want: http.StatusRequestEntityTooLarge},
+		{name: "unknown length", req: chunked(), want: http.StatusNoContent},
+	}
+	for _, tc := range tt {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, tc.req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: expected status %d, got %d", tc.name, tc.want, rec.Code)
+		}
+	}
+	if got := shutdowner.Stats().Rejected; got != 1 {
+		t.Errorf("expected 1 rejected request, got %d", got)
+	}
+}
+
+// FuzzUpgradeRequestHeaders covers the only header parsing of the middleware, the Connection and Upgrade headers used to
+// detect upgrade requests. The middleware must never panic and must reject exactly the requests with a non-empty Upgrade
+// header and an "upgrade" token in the Connection header.
+func FuzzUpgradeRequestHeaders(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"Upgrade", "websocket"},
+		{"keep-alive, Upgrade", "websocket"},
+		{"keep-alive,,upgrade ,", "h2c"},
+		{"  UPGRADE\t", "websocket"},
+		{"upgraded", "websocket"},
+		{"Upgrade", ""},
+		{"", "websocket"},
+		{",,,", ","},
+		{"up\x00grade", "\xff"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+
+	shutdowner := shutdown.NewShutdowner(shutdown.WithRejectUpgradesOnly())
+	if err := shutdowner.BeginShutdown(); err != nil {
+		f.Fatalf("no error expected but got %v", err)
+	}
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	f.Fuzz(func(t *testing.T, connection, upgrade string) {
+		r := httptest.NewRequest("GET", "/ws", nil)
+		r.Header["Connection"] = []string{connection}
+		r.Header["Upgrade"] = []string{upgrade}
+
+		wantRejected := false
+		if upgrade != "" {
+			for _, token := range strings.Split(connection, ",") {
+				if strings.EqualFold(strings.Trim(token, " \t"), "upgrade") {
+					wantRejected = true
+				}
+			}
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rejected := rec.Code == http.StatusServiceUnavailable; rejected != wantRejected {
+			t.Errorf("Connection %q, Upgrade %q: expected rejected=%t, got status %d", connection, upgrade, wantRejected, rec.Code)
+		}
+	})
+}
+
+func TestWithRejectionHandler(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(
+		shutdown.WithRejectUpgradesOnly(),
+		shutdown.WithRejectionHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "5")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})),
+	)
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newUpgradeRequest())
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "5" {
+		t.Errorf("expected the rejection handler to serve the rejected request, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestWithRejectNewRequests(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithRejectNewRequests())
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	var served []string
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(entered)
+			<-release
+		}
+		served = append(served, r.URL.Path)
+	}))
+
+	inFlight := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(inFlight, httptest.NewRequest("GET", "/slow", nil))
+	}()
+	<-entered
+	shutdownDone := make(chan error)
+	go func() {
+		shutdownDone <- shutdowner.Shutdown(context.Background())
+	}()
+	for !shutdowner.IsShuttingDown() {
+		runtime.Gosched()
+	}
+
+	for _, r := range []*http.Request{httptest.NewRequest("GET", "/new", nil), newUpgradeRequest()} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected status %d for %s, got %d", http.StatusServiceUnavailable, r.URL.Path, rec.Code)
+		}
+	}
+
+	close(release)
+	<-done
+	if err := <-shutdownDone; err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if inFlight.Code != http.StatusOK {
+		t.Errorf("expected the in-flight request to be served, got status %d", inFlight.Code)
+	}
+	if want := []string{"/slow"}; !slices.Equal(served, want) {
+		t.Errorf("expected served requests %v, got %v", want, served)
+	}
+}
+
+func TestWithRejectionHandler_NewRequests(t *testing.T) {
+	t.Parallel()
+	var shutdowner *shutdown.Shutdowner
+	var activeDuringRejection int64 = -1
+	shutdowner = shutdown.NewShutdowner(
+		shutdown.WithRejectNewRequests(),
+		shutdown.WithRejectionHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			activeDuringRejection = shutdowner.ActiveCount()
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "5")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = fmt.Fprintf(w, `{"error":{"code":"shutting_down","path":%q}}`, r.URL.Path)
+		})),
+	)
+	var served bool
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served = true }))
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/orders", nil))
+	if served {
+		t.Errorf("expected the request to be rejected")
+	}
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "5" {
+		t.Errorf("expected the rejection handler to serve the rejected request, got %d %v", rec.Code, rec.Header())
+	}
+	if got, want := rec.Body.String(), `{"error":{"code":"shutting_down","path":"/api/orders"}}`; got != want {
+		t.Errorf("expected body %s, got %s", want, got)
+	}
+	if activeDuringRejection != 0 {
+		t.Errorf("expected the rejected request not to be counted as active, got %d", activeDuringRejection)
+	}
+}
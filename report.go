@@ -0,0 +1,42 @@
+package shutdown
+
+import "time"
+
+// Report summarizes the outcome of a drain.
+type Report struct {
+	// Cause is the reason for the shutdown as passed to BeginShutdownCause, or ErrShuttingDown if none was given.
+	Cause error
+	// Err is the error returned by Shutdown, e.g. context.DeadlineExceeded if the drain timed out. It is nil if all
+	// handlers returned in time. Unlike Cause, it tells how the drain ended, not why it began.
+	Err error
+	// StartedAt is the time shutdown began.
+	StartedAt time.Time
+	// Duration is the time from the beginning of the shutdown until Shutdown returned.
+	Duration time.Duration
+	// ActiveAtStart is the number of active handlers when shutdown began.
+	ActiveAtStart int64
+	// Remaining is the number of handlers that were still active when Shutdown returned.
+	Remaining int64
+	// Completed is the number of handlers that returned between the beginning of the shutdown and the return of
+	// Shutdown.
+	Completed int64
+	// ForceClosed is the number of tracked connections that were closed because the drain timed out, see
+	// WithForceCloseOnTimeout.
+	ForceClosed int
+	// ForceClosedPaths holds the request paths of the force-closed connections that were obtained by hijacking with
+	// WithHijackTracking, sorted. Connections passed to TrackConn directly have no known path and are only counted.
+	ForceClosedPaths []string
+	// HookErr joins the errors returned by the callbacks registered with OnShutdownErr until Shutdown returned.
+	HookErr error
+}
+
+// Report returns the report of the most recent Shutdown call that returned. The boolean result is false if no Shutdown
+// call has returned yet.
+func (g *Shutdowner) Report() (Report, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.report == nil {
+		return Report{}, false
+	}
+	return *g.report, true
+}
@@ -0,0 +1,184 @@
+package shutdown_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_BeginShutdownCause(t *testing.T) {
+	t.Parallel()
+	var logs bytes.Buffer
+	shutdowner := shutdown.NewShutdowner(
+		shutdown.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		shutdown.WithContextCancellation(),
+	)
+	events, unsubscribe := shutdowner.Events(10)
+	defer unsubscribe()
+
+	if _, ok := shutdowner.Report(); ok {
+		t.Errorf("expected no report before shutdown")
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	causes := make(chan error, 1)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			causes <- context.Cause(r.Context())
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	<-started
+
+	deploy := errors.New("deploy v2")
+	shutdowner.BeginShutdownCause(deploy)
+	shutdowner.BeginShutdownCause(errors.New("ignored"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, but got %v", context.DeadlineExceeded, err)
+	}
+
+	report, ok := shutdowner.Report()
+	if !ok {
+		t.Fatalf("expected a report after Shutdown returned")
+	}
+	if !errors.Is(report.Cause, deploy) {
+		t.Errorf("expected report cause %v, got %v", deploy, report.Cause)
+	}
+	if !errors.Is(report.Err, context.DeadlineExceeded) {
+		t.Errorf("expected report error %v, got %v", context.DeadlineExceeded, report.Err)
+	}
+	if report.ActiveAtStart != 1 || report.Remaining != 1 {
+		t.Errorf("expected 1 active handler at start and 1 remaining, got %d and %d",
+			report.ActiveAtStart, report.Remaining)
+	}
+
+	close(release)
+	<-finished
+	if cause := <-causes; !errors.Is(cause, deploy) || !errors.Is(cause, shutdown.ErrShuttingDown) {
+		t.Errorf("expected request context cause to wrap %v and %v, got %v", deploy, shutdown.ErrShuttingDown, cause)
+	}
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if report, _ := shutdowner.Report(); report.Err != nil || !errors.Is(report.Cause, deploy) {
+		t.Errorf("expected successful report with cause %v, got %+v", deploy, report)
+	}
+
+	for _, want := range []shutdown.EventType{
+		shutdown.EventShutdownBegan, shutdown.EventDrainTimedOut, shutdown.EventDrainCompleted,
+	} {
+		e := <-events
+		if e.Type != want || !errors.Is(e.Cause, deploy) {
+			t.Errorf("expected %v event with cause %v, got %v with cause %v", want, deploy, e.Type, e.Cause)
+		}
+	}
+
+	for _, want := range []string{"shutdown began", "drain timed out", "drain completed"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("expected logs to contain %q, got %s", want, logs.String())
+		}
+	}
+	if got := strings.Count(logs.String(), "cause=\"deploy v2\""); got != 3 {
+		t.Errorf("expected cause in all 3 log lines, got %d in %s", got, logs.String())
+	}
+}
+
+func TestShutdowner_BeginShutdown_DefaultCause(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+	shutdowner.BeginShutdown()
+	if !shutdowner.IsShuttingDown() {
+		t.Errorf("expected shutdown to have begun")
+	}
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if report, _ := shutdowner.Report(); !errors.Is(report.Cause, shutdown.ErrShuttingDown) {
+		t.Errorf("expected default cause %v, got %v", shutdown.ErrShuttingDown, report.Cause)
+	}
+}
+
+func TestReport_ForceClosed(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithHijackTracking(), shutdown.WithForceCloseOnTimeout())
+
+	hijacked := make(chan struct{}, 2)
+	server := httptest.NewServer(shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" {
+			<-shutdowner.ShutdownContext().Done()
+			return
+		}
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("failed to hijack: %v", err)
+			return
+		}
+		hijacked <- struct{}{}
+		// blocks until the connection is force-closed
+		_, _ = conn.Read(make([]byte, 1))
+	})))
+	defer server.Close()
+
+	for _, path := range []string{"/ws/a", "/ws/b"} {
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		defer conn.Close()
+		if _, err := conn.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: test\r\n\r\n")); err != nil {
+			t.Fatalf("failed to write request: %v", err)
+		}
+	}
+	<-hijacked
+	<-hijacked
+	apiDone := make(chan struct{})
+	go func() {
+		defer close(apiDone)
+		resp, err := server.Client().Get(server.URL + "/api")
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	for shutdowner.ActiveCount() != 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the drain to time out, got %v", err)
+	}
+	<-apiDone
+
+	report, _ := shutdowner.Report()
+	if report.Completed != 1 {
+		t.Errorf("expected 1 completed handler, got %d", report.Completed)
+	}
+	if report.ForceClosed != 2 {
+		t.Errorf("expected 2 force-closed connections, got %d", report.ForceClosed)
+	}
+	if want := []string{"/ws/a", "/ws/b"}; strings.Join(report.ForceClosedPaths, ",") != strings.Join(want, ",") {
+		t.Errorf("expected force-closed paths %v, got %v", want, report.ForceClosedPaths)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Errorf("expected the force-closed handlers to return, got %v", err)
+	}
+}
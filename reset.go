@@ -0,0 +1,54 @@
+package shutdown
+
+import "time"
+
+// Reset returns the Shutdowner to the state it had before shutdown began, so that it can be reused, e.g. by integration
+// tests that start and stop a server repeatedly: IsShuttingDown reports false again, Done returns a new channel, the
+// middleware stops rejecting requests and the next Shutdown performs a complete drain, including the OnShutdown and
+// AfterDrain hooks registered after Reset. The hooks registered before the previous shutdown are not run again, while
+// the configuration and the cumulative counters of Stats are kept.
+//
+// Reset panics if handlers, background tasks or OnShutdown hooks are still active, i.e. if the previous drain has not
+// completed. It must not be called concurrently with other methods of the Shutdowner.
+func (g *Shutdowner) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.wg.n.Load() != 0 {
+		panic("shutdown: Reset called while handlers are still active")
+	}
+	if g.hooksDone != nil {
+		select {
+		case <-g.hooksDone:
+		default:
+			panic("shutdown: Reset called while OnShutdown hooks are still running")
+		}
+	}
+
+	g.shuttingDown.Store(false)
+	g.beganAt.Store(0)
+	g.drained.Store(false)
+	g.webhookSent.Store(0)
+	g.summaryLogged.Store(false)
+	g.base.Store(nil)
+
+	g.cause = nil
+	g.activeAtStart = 0
+	g.finishedAtStart = 0
+	g.statsAtStart = Stats{}
+	g.rateSamples = nil
+	g.report = nil
+	g.startedAt = time.Time{}
+	g.deadline = time.Time{}
+	g.abandoned = nil
+	g.beginCtx = nil
+	g.hookNames = nil
+	g.hookErrs = nil
+	g.hooksDone = nil
+	g.handlersDone = nil
+	g.afterDrain = nil
+	g.afterDrainRun = false
+
+	g.handlerMu.Lock()
+	g.handlersCancelled = nil
+	g.handlerMu.Unlock()
+}
@@ -0,0 +1,64 @@
+package shutdown_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_Reset(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithRejectNewRequests())
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for cycle := range 2 {
+		done := shutdowner.Done()
+		select {
+		case <-done:
+			t.Fatalf("cycle %d: expected Done to be open", cycle)
+		default:
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("cycle %d: expected the request to be served, got %d", cycle, rec.Code)
+		}
+
+		var hookCalled bool
+		shutdowner.OnShutdown(func(ctx context.Context) { hookCalled = true })
+		if err := shutdowner.Shutdown(context.Background()); err != nil {
+			t.Fatalf("cycle %d: no error expected but got %v", cycle, err)
+		}
+		if !hookCalled {
+			t.Errorf("cycle %d: expected the OnShutdown hook to be called", cycle)
+		}
+		<-done
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("cycle %d: expected the request to be rejected, got %d", cycle, rec.Code)
+		}
+
+		shutdowner.Reset()
+		if shutdowner.IsShuttingDown() || shutdowner.IsDrained() {
+			t.Errorf("cycle %d: expected a fresh state after Reset", cycle)
+		}
+	}
+}
+
+func TestShutdowner_Reset_Active(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+	done := shutdowner.Track()
+	defer done()
+
+	defer func() {
+		if v := recover(); v == nil {
+			t.Errorf("expected Reset to panic while handlers are active")
+		}
+	}()
+	shutdowner.Reset()
+}
@@ -0,0 +1,147 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// WithConnectionCloseOnDrain configures the middleware to add a "Connection: close" header to responses that start
+// after shutdown began, so that clients close their keep-alive connections and reconnect elsewhere instead of sending
+// more requests to the draining instance. The header is only added if the handler has not written the response header
+// yet. Combine it with InstrumentServer to also close connections that are idle.
+func WithConnectionCloseOnDrain() Option {
+	return func(g *Shutdowner) {
+		g.cfg.connectionCloseOnDrain = true
+	}
+}
+
+// InstrumentServer hooks the Shutdowner into the connection state changes of server by means of its ConnState
+// callback, which keeps calling the callback previously set, if any. Once shutdown begins, connections that are idle
+// are closed, and connections becoming idle afterward are closed as well, so that keep-alive clients reconnect to
+// another instance quickly, even before server.Shutdown is called. It must be called before the server starts serving.
+//
+// The instrumentation also counts the connections of the server, see ActiveConns, and keeps track of the connections
+// hijacked from it, e.g. by websocket handshakes, until they are closed, see HijackedConns. Shutdown waits for the
+// hijacked connections to be closed after the handlers returned, which makes InstrumentServer an alternative to
+// Middleware for handlers that cannot be wrapped. If both are used, nothing is counted twice: the handlers are counted
+// by Middleware and waited for first, and the hijacked connections that outlive them are waited for afterward.
+// Connections that don't expose their file descriptor, i.e. that implement neither syscall.Conn nor a NetConn method
+// returning such a connection, as *tls.Conn does, are not tracked once hijacked.
+func (g *Shutdowner) InstrumentServer(server *http.Server) {
+	prev := server.ConnState
+	server.ConnState = func(conn net.Conn, state http.ConnState) {
+		g.connStateChanged(conn, state)
+		if prev != nil {
+			prev(conn, state)
+		}
+	}
+}
+
+// connStateChanged records the state of a server connection and closes it if it became idle after shutdown began.
+func (g *Shutdowner) connStateChanged(conn net.Conn, state http.ConnState) {
+	g.serverConnMu.Lock()
+	switch state {
+	case http.StateNew, http.StateActive, http.StateIdle:
+		if g.serverConns == nil {
+			g.serverConns = make(map[net.Conn]http.ConnState)
+		}
+		g.serverConns[conn] = state
+	case http.StateHijacked:
+		delete(g.serverConns, conn)
+		if raw := rawConn(conn); raw != nil {
+			if g.hijackedConns == nil {
+				g.hijackedConns = make(map[net.Conn]syscall.RawConn)
+			}
+			g.hijackedConns[conn] = raw
+		}
+	default:
+		delete(g.serverConns, conn)
+	}
+	g.serverConnMu.Unlock()
+
+	if state == http.StateIdle && g.shuttingDown.Load() {
+		g.closeServerConn(conn)
+	}
+}
+
+// closeIdleConns closes the idle connections of the servers instrumented with InstrumentServer.
+func (g *Shutdowner) closeIdleConns() {
+	g.serverConnMu.Lock()
+	var idle []net.Conn
+	for conn, state := range g.serverConns {
+		if state == http.StateIdle {
+			idle = append(idle, conn)
+		}
+	}
+	g.serverConnMu.Unlock()
+
+	for _, conn := range idle {
+		g.closeServerConn(conn)
+	}
+}
+
+// closeServerConn closes a connection of an instrumented server.
+func (g *Shutdowner) closeServerConn(conn net.Conn) {
+	if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		g.log(slog.LevelWarn, "failed to close idle connection", slog.Any("error", err))
+	}
+}
+
+// ActiveConns returns the number of connections of the servers instrumented with InstrumentServer that are currently
+// serving a request, not counting idle and hijacked connections.
+func (g *Shutdowner) ActiveConns() int {
+	g.serverConnMu.Lock()
+	defer g.serverConnMu.Unlock()
+	var n int
+	for _, state := range g.serverConns {
+		if state == http.StateActive {
+			n++
+		}
+	}
+	return n
+}
+
+// HijackedConns returns the number of connections hijacked from the servers instrumented with InstrumentServer that
+// have not been closed yet.
+func (g *Shutdowner) HijackedConns() int {
+	g.serverConnMu.Lock()
+	defer g.serverConnMu.Unlock()
+	for conn, raw := range g.hijackedConns {
+		// the descriptor of a closed connection can no longer be accessed
+		if raw.Control(func(fd uintptr) {}) != nil {
+			delete(g.hijackedConns, conn)
+		}
+	}
+	return len(g.hijackedConns)
+}
+
+// waitHijackedConns waits until all connections hijacked from instrumented servers are closed.
+func (g *Shutdowner) waitHijackedConns(ctx context.Context, abandoned <-chan struct{}) error {
+	if g.HijackedConns() == 0 {
+		return nil
+	}
+	return g.poll(ctx, abandoned, func() bool { return g.HijackedConns() == 0 })
+}
+
+// rawConn returns the raw network connection of conn to check whether it has been closed, or nil if conn does not
+// expose it.
+func rawConn(conn net.Conn) syscall.RawConn {
+	for {
+		switch c := conn.(type) {
+		case syscall.Conn:
+			raw, err := c.SyscallConn()
+			if err != nil {
+				return nil
+			}
+			return raw
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package shutdown_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_InstrumentServer_ConnectionCloseOnDrain(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithConnectionCloseOnDrain())
+
+	server := httptest.NewUnstartedServer(shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	})))
+	states := make(chan http.ConnState, 100)
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		states <- state
+	}
+	shutdowner.InstrumentServer(server.Config)
+	server.Start()
+	defer server.Close()
+
+	var dials atomic.Int32
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+	get := func() *http.Response {
+		t.Helper()
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+	awaitState := func(want http.ConnState) {
+		t.Helper()
+		for {
+			select {
+			case state := <-states:
+				if state == want {
+					return
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("timed out waiting for connection state %v", want)
+			}
+		}
+	}
+
+	if resp := get(); resp.Close {
+		t.Errorf("expected the connection to be kept alive before shutdown")
+	}
+	awaitState(http.StateIdle)
+
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	awaitState(http.StateClosed)
+
+	if resp := get(); !resp.Close {
+		t.Errorf("expected the response during drain to close the connection")
+	}
+	awaitState(http.StateClosed)
+	get()
+	if got := dials.Load(); got != 3 {
+		t.Errorf("expected each request during drain to use a new connection, got %d dials", got)
+	}
+}
+
+func TestShutdowner_InstrumentServer_HijackedConns(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	hijacked := make(chan struct{})
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(entered)
+			<-release
+			return
+		}
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("failed to hijack: %v", err)
+			return
+		}
+		// the connection outlives the handler
+		go func() {
+			<-release
+			conn.Close()
+		}()
+		close(hijacked)
+	}))
+	shutdowner.InstrumentServer(server.Config)
+	server.Start()
+	defer server.Close()
+
+	dial := func(path string) net.Conn {
+		t.Helper()
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		_, _ = io.WriteString(conn, "GET "+path+" HTTP/1.1\r\nHost: example.com\r\n\r\n")
+		return conn
+	}
+	defer dial("/ws").Close()
+	<-hijacked
+	defer dial("/slow").Close()
+	<-entered
+
+	if got := shutdowner.HijackedConns(); got != 1 {
+		t.Errorf("expected 1 hijacked connection, got %d", got)
+	}
+	if got := shutdowner.ActiveConns(); got != 1 {
+		t.Errorf("expected 1 active connection, got %d", got)
+	}
+	if got := shutdowner.ActiveCount(); got != 0 {
+		t.Errorf("expected no handlers to be counted without Middleware, got %d", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err == nil {
+		t.Fatalf("expected the drain to wait for the hijacked connection")
+	}
+	close(release)
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+	if got := shutdowner.HijackedConns(); got != 0 {
+		t.Errorf("expected no hijacked connections, got %d", got)
+	}
+}
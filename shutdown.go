@@ -3,8 +3,15 @@ package shutdown
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 // Shutdowner helps with gracefully shutting down http.Handler's that are not taken into account by http/Server.Shutdown
@@ -13,50 +20,556 @@ import (
 // hijacked connection continues to be used after the http.Handler has returned, Shutdowner will consider that
 // connection as inactive and won't prevent the application shutdown from proceeding.
 type Shutdowner struct {
-	wg sync.WaitGroup
+	cfg config
+
+	wg       group
+	active   atomic.Int64
+	started  atomic.Int64
+	finished atomic.Int64
+	rejected atomic.Int64
+	classes  sync.Map // class name -> *counters
+	labels   sync.Map // label -> *labelState
+
+	deltaMu   sync.Mutex
+	deltaBase Stats // the counters at the last call of SnapshotAndReset
+
+	validating atomic.Int32
+
+	connMu sync.Mutex
+	conns  map[uint64]*trackedConn
+
+	handlerMu         sync.Mutex
+	handlerCancels    map[uint64]context.CancelCauseFunc
+	handlersCancelled error
+
+	serverConnMu  sync.Mutex
+	serverConns   map[net.Conn]http.ConnState
+	hijackedConns map[net.Conn]syscall.RawConn
+
+	completionMu sync.Mutex
+	completionN  atomic.Int32
+	completions  map[*completionListener]struct{}
+
+	trackMu  sync.Mutex
+	nextID   atomic.Uint64
+	handlers map[uint64]HandlerInfo
+
+	shuttingDown  atomic.Bool
+	beganAt       atomic.Int64 // unix nanoseconds
+	drained       atomic.Bool
+	webhookSent   atomic.Uint32
+	summaryLogged atomic.Bool
+
+	base   atomic.Pointer[baseState]
+	events eventSubscribers
+
+	mu              sync.Mutex
+	cause           error
+	activeAtStart   int64
+	finishedAtStart int64
+	statsAtStart    Stats
+	rateSamples     []rateSample
+	report          *Report
+	startedAt       time.Time
+	deadline        time.Time
+	abandoned       chan struct{}
+	beginCtx        context.Context
+	hooks           []func(ctx context.Context) error
+	hookNames       map[string]struct{}
+	hookErrs        []error
+	hooksDone       chan struct{}
+	handlersDone    chan struct{}
+	afterDrain      []func(err error, remaining int64)
+	afterDrainRun   bool
+	sources         []WaitSource
+	barriers        []*Barrier
+
+	testHookServe func(r *http.Request) // called by the middleware right before the wrapped handler, if set
 }
 
 // Middleware wraps the invocation of the given handler so that Shutdown can be used to ensure that all handlers have
-// returned.
+// returned. A nil handler is a misuse, see WithStrictMode; it is replaced with http.NotFoundHandler otherwise.
 func (g *Shutdowner) Middleware(next http.Handler) http.Handler {
+	if next == nil {
+		g.misuse("Middleware called with a nil handler")
+		next = http.NotFoundHandler()
+	}
+	if _, ok := next.(untrackedHandler); ok {
+		return next
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		g.wg.Add(1)
-		defer g.wg.Done()
+		if g.validate(w, r) {
+			return
+		}
+		if !g.participates(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if g.cfg.skipCancelled && r.Context().Err() != nil {
+			return
+		}
+		var started time.Time
+		if g.drainRequestLogEnabled() {
+			started = time.Now()
+		}
+		cc := g.classCounters(r)
+		if g.shuttingDown.Load() && g.reject(w, r) {
+			g.rejected.Add(1)
+			if g.cfg.metrics != nil {
+				g.cfg.metrics.RequestRejected()
+			}
+			if cc != nil {
+				cc.rejected.Add(1)
+			}
+			if g.drainRequestLogEnabled() {
+				g.logDrainRequest(r, "rejected", started)
+			}
+			return
+		}
+		var rw *responseWriter
+		if g.cfg.hijackTracking || g.cfg.countFromFirstWrite || g.cfg.connectionCloseOnDrain {
+			rw = &responseWriter{ResponseWriter: w, g: g, cc: cc, path: r.URL.Path}
+			w = rw
+		}
+		if g.cfg.countFromFirstWrite {
+			defer rw.leave()
+		} else {
+			g.enter(cc)
+			defer g.leave(cc)
+		}
+		if g.cfg.recover != nil {
+			defer g.recoverPanic(w, r)
+		}
+		if g.cfg.tracking && g.sampled() {
+			defer g.untrack(g.track(r.Method, r.URL.Path))
+		}
+		if g.drainRequestLogEnabled() {
+			defer g.logDrainRequest(r, "served", started)
+		}
+		switch {
+		case g.cfg.contextCancellation:
+			dc := &drainContext{Context: r.Context(), g: g}
+			defer dc.release()
+			r = r.WithContext(dc)
+		case g.cfg.contextInjection:
+			ctx, release := g.handlerContext(context.WithValue(r.Context(), contextKey{}, g))
+			defer release()
+			r = r.WithContext(ctx)
+		}
+		if g.cfg.maxSpawn > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), spawnKey{}, new(atomic.Int32)))
+		}
+		if g.cfg.grace != nil {
+			if grace := g.cfg.grace(r); grace > 0 {
+				ctx, release := g.graceContext(r.Context(), grace)
+				defer release()
+				r = r.WithContext(ctx)
+			}
+		}
+		if g.cfg.maxHandlerDuration > 0 {
+			ctx, cancel := context.WithTimeoutCause(r.Context(), g.cfg.maxHandlerDuration, ErrMaxHandlerDurationExceeded)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		if g.testHookServe != nil {
+			g.testHookServe(r)
+		}
+		if g.cfg.pprofLabels {
+			g.serveWithLabels(next, w, r)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
-// Shutdown waits for all active handlers to finish. If the context is cancelled before all handlers finish, the
-// function returns the context error. If all handlers finish before the context is cancelled, the function returns nil.
+// participates reports whether r takes part in the drain according to the predicates configured with
+// WithDrainPredicate.
+func (g *Shutdowner) participates(r *http.Request) bool {
+	for _, p := range g.cfg.drainPredicates {
+		if !p(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// enter counts a handler as active.
+func (g *Shutdowner) enter(cc *counters) {
+	g.wg.Add(1)
+	g.active.Add(1)
+	g.started.Add(1)
+	if cc != nil {
+		cc.active.Add(1)
+		cc.started.Add(1)
+	}
+	if g.cfg.metrics != nil {
+		g.cfg.metrics.HandlerStarted()
+	}
+	g.emitHandler(EventHandlerStarted)
+}
+
+// leave counts a handler counted by enter as finished. An extra call without a matching enter is reported as misuse and
+// otherwise ignored.
+func (g *Shutdowner) leave(cc *counters) {
+	remaining := g.active.Add(-1)
+	if remaining < 0 {
+		g.active.Add(1)
+		g.misuse("handler counted as finished more often than as started")
+		return
+	}
+	if cc != nil {
+		cc.active.Add(-1)
+		cc.finished.Add(1)
+	}
+	g.finished.Add(1)
+	if g.cfg.metrics != nil {
+		g.cfg.metrics.HandlerFinished()
+	}
+	g.emitHandler(EventHandlerFinished)
+	g.notifyCompletion(remaining)
+	g.notifyCountProgress(remaining)
+	g.logHandlerFinished(remaining)
+	g.done()
+}
+
+// done marks an operation counted in g.wg as finished, reporting an extra call as misuse instead of letting the counter
+// become negative.
+func (g *Shutdowner) done() {
+	if !g.wg.Done() {
+		g.misuse("negative handler counter")
+	}
+}
+
+// Shutdown waits for all active handlers to finish. If the context is done before all handlers finish, the function
+// returns a *DrainTimeoutError or *DrainCancelledError wrapping the context error, depending on whether the deadline
+// was exceeded or the context was cancelled. If all handlers finish before the context is done, the function returns
+// nil. If ctx has no deadline, the drain is bounded by the timeout configured with WithDrainTimeout, if any.
+//
+// Shutdown can be called concurrently and repeatedly; the callers share a single wait for the handlers, each bounded by
+// its own context. Once a call drained the handlers, later calls return nil immediately.
 func (g *Shutdowner) Shutdown(ctx context.Context) error {
-	d := make(chan struct{})
+	ctx, cancel := g.withDrainTimeout(ctx)
+	defer cancel()
+
+	if err := g.begin(ctx, nil, true); err != nil {
+		return err
+	}
+
+	abandoned := g.abandonedChan()
+	select {
+	case <-abandoned:
+		return ErrAborted
+	default:
+	}
+
+	if g.drained.Load() {
+		// a previous call drained the handlers, so there is nothing left to wait for
+		return nil
+	}
+
+	g.mu.Lock()
+	handlersDone := g.handlersDone
+	sources := g.sources
+	g.mu.Unlock()
+	defer g.reportDrainProgress(handlersDone)()
+
+	var err error
+	select {
+	case <-handlersDone:
+	case <-abandoned:
+		err = ErrAborted
+	case <-ctx.Done():
+		// the handlers may have returned before ctx was done, e.g. while a server was shut down with the same ctx
+		select {
+		case <-handlersDone:
+		default:
+			err = ctx.Err()
+		}
+	}
+	if err == nil {
+		err = g.awaitSources(ctx, abandoned, sources)
+	}
+	if err == nil {
+		err = g.quiesce(ctx, abandoned)
+	}
+	if err == nil {
+		err = g.waitConns(ctx, abandoned)
+	}
+	if err == nil {
+		err = g.waitHijackedConns(ctx, abandoned)
+	}
+	err = g.drainError(ctx, err)
+	switch {
+	case errors.Is(err, ErrAborted):
+		return err
+	case err == nil:
+		g.drained.Store(true)
+	}
+	g.finish(err)
+	return err
+}
+
+// Wait blocks until no handlers and no background tasks started with GoNamed, Go or Track are active. Unlike Shutdown,
+// it does not begin the shutdown, so it can be used to synchronize in the middle of the lifecycle, e.g. in tests. Since
+// requests keep being accepted, Wait may block indefinitely if new ones keep arriving.
+func (g *Shutdowner) Wait() {
+	g.wg.Wait()
+}
+
+// BeginShutdown begins the shutdown without waiting for the handlers to finish, i.e. the middleware starts to reject
+// requests according to its configuration, request contexts are cancelled if configured, and the OnShutdown hooks are
+// run. Call Shutdown afterward to wait for the drain. It returns the error of the guard configured with
+// WithShutdownGuard if the guard vetoes the shutdown.
+func (g *Shutdowner) BeginShutdown() error {
+	return g.begin(context.Background(), nil, true)
+}
+
+// BeginShutdownCause is like BeginShutdown, but records cause as the reason for the shutdown, e.g.
+// errors.New("SIGTERM received"). The cause is included in the logs, the events and the Report, and contexts that are
+// cancelled at shutdown report an error wrapping both ErrShuttingDown and cause from context.Cause. It has no effect if
+// shutdown has already begun.
+func (g *Shutdowner) BeginShutdownCause(cause error) error {
+	return g.begin(context.Background(), cause, true)
+}
+
+// finish records the outcome of a Shutdown call that observed the drain to complete, or err if it did not.
+func (g *Shutdowner) finish(err error) {
+	remaining := g.active.Load()
+	completed := g.finished.Load()
+	var forceClosed []string
+	if err != nil && g.cfg.forceCloseOnTimeout {
+		g.waitBarriers()
+		forceClosed = g.forceClose()
+	}
+
+	g.mu.Lock()
+	r := Report{
+		Cause:         g.cause,
+		Err:           err,
+		StartedAt:     g.startedAt,
+		Duration:      time.Since(g.startedAt),
+		ActiveAtStart: g.activeAtStart,
+		Remaining:     remaining,
+		Completed:     completed - g.finishedAtStart,
+		ForceClosed:   len(forceClosed),
+		HookErr:       errors.Join(g.hookErrs...),
+	}
+	for _, path := range forceClosed {
+		if path != "" {
+			r.ForceClosedPaths = append(r.ForceClosedPaths, path)
+		}
+	}
+	sort.Strings(r.ForceClosedPaths)
+	g.report = &r
+	rejected := g.rejected.Load() - g.statsAtStart.Rejected
+	g.mu.Unlock()
+	g.runAfterDrain(err, remaining)
+	g.logSummary(r, rejected)
+	if g.cfg.metrics != nil {
+		g.cfg.metrics.DrainCompleted(r.Duration, int(remaining))
+	}
+
+	if err != nil {
+		g.notifyWebhook(webhookTimeout)
+		g.emit(Event{Type: EventDrainTimedOut, Time: time.Now(), Active: remaining, Cause: r.Cause})
+		g.log(slog.LevelWarn, "drain timed out", slog.Int64("remaining", remaining),
+			slog.Duration("duration", r.Duration), slog.Any("cause", r.Cause), slog.Any("error", err))
+		return
+	}
+	g.removeMarkerFile()
+	g.notifyWebhook(webhookDrained)
+	g.emit(Event{Type: EventDrainCompleted, Time: time.Now(), Active: remaining, Cause: r.Cause})
+	g.log(slog.LevelInfo, "drain completed", slog.Duration("duration", r.Duration), slog.Any("cause", r.Cause))
+}
+
+// waitHandlers returns a channel that is closed once all handlers have returned and hooksDone is closed. It is started
+// when shutdown begins and shared by all calls of Shutdown.
+func (g *Shutdowner) waitHandlers(hooksDone <-chan struct{}) chan struct{} {
+	done := make(chan struct{})
 	go func() {
 		g.wg.Wait()
-		close(d)
+		<-hooksDone
+		close(done)
 	}()
+	return done
+}
+
+// AbandonDrain gives up on draining: any in-progress and future Shutdown calls return ErrAborted immediately and
+// IsDrained reports true. Handlers that are still in flight keep running, but are no longer waited on.
+//
+// This is meant for emergencies only, e.g. when an operator decides that the application must proceed with shutting
+// down regardless of the active handlers. Any work those handlers are still doing may be cut off when the process
+// exits. Each Shutdown call released by AbandonDrain leaves behind a goroutine that waits for the stuck handlers and
+// only exits once they have returned. AbandonDrain bypasses the guard configured with WithShutdownGuard.
+func (g *Shutdowner) AbandonDrain() {
+	_ = g.begin(context.Background(), nil, false)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.drained.Store(true)
 	select {
-	case <-d:
+	case <-g.abandonedLocked():
+	default:
+		close(g.abandonedLocked())
+	}
+}
+
+// IsDrained reports whether a Shutdown call has observed all handlers to have returned, or whether the drain has been
+// abandoned with AbandonDrain.
+func (g *Shutdowner) IsDrained() bool {
+	return g.drained.Load()
+}
+
+// IsShuttingDown reports whether shutdown has begun.
+func (g *Shutdowner) IsShuttingDown() bool {
+	return g.shuttingDown.Load()
+}
+
+// ShutdownStartedAt returns the time shutdown began. The boolean result is false if shutdown has not begun yet. It does
+// not lock, so handlers can call it freely, e.g. to compute how long ago shutdown began.
+func (g *Shutdowner) ShutdownStartedAt() (time.Time, bool) {
+	ns := g.beganAt.Load()
+	if ns == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ns), true
+}
+
+// String returns a compact summary of the state of the Shutdowner for debug logging, e.g.
+// `shutdowner "api" (active=3 shuttingDown=true drained=false)`. It is safe to call concurrently.
+func (g *Shutdowner) String() string {
+	return fmt.Sprintf("shutdowner %q (active=%d shuttingDown=%t drained=%t)",
+		g.cfg.name, g.active.Load(), g.shuttingDown.Load(), g.drained.Load())
+}
+
+// abandonedChan returns the channel that is closed by AbandonDrain.
+func (g *Shutdowner) abandonedChan() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.abandonedLocked()
+}
+
+// abandonedLocked lazily initializes the channel that is closed by AbandonDrain. g.mu must be held.
+func (g *Shutdowner) abandonedLocked() chan struct{} {
+	if g.abandoned == nil {
+		g.abandoned = make(chan struct{})
+	}
+	return g.abandoned
+}
+
+// begin marks the Shutdowner as shutting down, records the deadline of ctx, if any, and runs the OnShutdown hooks the
+// first time it is called. When begin is called multiple times, the earliest deadline wins and the cause of the first
+// call is kept. A nil cause is recorded as ErrShuttingDown. If guarded is true and shutdown has not begun yet, the guard
+// configured with WithShutdownGuard is consulted first and its error is returned if it vetoes the shutdown.
+func (g *Shutdowner) begin(ctx context.Context, cause error, guarded bool) error {
+	if guarded && g.cfg.guard != nil && !g.shuttingDown.Load() {
+		if err := g.cfg.guard(); err != nil {
+			g.log(slog.LevelWarn, "shutdown vetoed by guard", slog.Any("error", err))
+			return err
+		}
+	}
+
+	g.mu.Lock()
+	if d, ok := ctx.Deadline(); ok && (g.deadline.IsZero() || d.Before(g.deadline)) {
+		g.deadline = d
+	}
+	if g.shuttingDown.Load() {
+		g.mu.Unlock()
 		return nil
-	case <-ctx.Done():
-		return ctx.Err()
 	}
+	if cause == nil {
+		cause = ErrShuttingDown
+	}
+	active := g.active.Load()
+	startedAt := time.Now()
+	g.startedAt = startedAt
+	g.beganAt.Store(startedAt.UnixNano())
+	g.beginCtx = ctx
+	g.activeAtStart = active
+	g.finishedAtStart = g.finished.Load()
+	g.rateSamples = []rateSample{{at: startedAt, finished: g.finishedAtStart}}
+	g.statsAtStart = Stats{Active: active, Started: g.started.Load(), Finished: g.finishedAtStart,
+		Rejected: g.rejected.Load()}
+	g.cause = cause
+	g.baseStateLocked().cancel(shutdownCause(cause))
+	g.hooksDone = g.runHooks(ctx, g.hooks)
+	g.handlersDone = g.waitHandlers(g.hooksDone)
+	g.hooks = nil
+	g.shuttingDown.Store(true)
+	g.mu.Unlock()
+
+	g.disableKeepAlives()
+	g.closeIdleConns()
+	g.notifyStopping(active)
+	g.writeMarkerFile(startedAt)
+	g.notifyWebhook(webhookShuttingDown)
+	g.emit(Event{Type: EventShutdownBegan, Time: time.Now(), Active: active, Cause: cause})
+	g.log(slog.LevelInfo, "shutdown began", slog.Int64("active", active), slog.Any("cause", cause))
+	return nil
+}
+
+// shutdownCause returns the cause of the cancellation of contexts cancelled at shutdown.
+func shutdownCause(cause error) error {
+	if cause == ErrShuttingDown {
+		return cause
+	}
+	return fmt.Errorf("%w: %w", ErrShuttingDown, cause)
 }
 
-// ShutdownWithServer shuts down the server and the shutdowner concurrently, waiting wor both respective Shutdown methods
-// to return and returning any errors that occurred with errors.Join.
+// ShutdownWithServer shuts down the server and the shutdowner concurrently, waiting for both respective Shutdown
+// methods to return and returning any errors that occurred with errors.Join. Shutdown begins before the server is shut
+// down, so handlers are signalled and the OnShutdown hooks are started before the server closes any connections. If the
+// guard configured with WithShutdownGuard vetoes the shutdown, the server is not shut down and the guard's error is
+// returned.
 func (g *Shutdowner) ShutdownWithServer(ctx context.Context, server *http.Server) error {
-	var serverErr, shutdownerErr error
+	return g.ShutdownWithServers(ctx, server)
+}
 
-	var wg sync.WaitGroup
-	wg.Add(1)
+// ShutdownWithServers is like ShutdownWithServer for several servers, e.g. an application server and an admin server,
+// which are all shut down concurrently with the shutdowner. An error of one server does not prevent the others from
+// being shut down. Nil servers are skipped.
+func (g *Shutdowner) ShutdownWithServers(ctx context.Context, servers ...*http.Server) error {
+	ctx, cancel := g.withDrainTimeout(ctx)
+	defer cancel()
 
-	go func() {
-		defer wg.Done()
-		shutdownerErr = g.Shutdown(ctx)
-	}()
+	if err := g.begin(ctx, nil, true); err != nil {
+		return err
+	}
 
-	serverErr = server.Shutdown(ctx)
+	// the last error is the shutdowner's
+	errs := make([]error, len(servers)+1)
+	var wg sync.WaitGroup
+	// if there is nothing to drain concurrently with the servers, spare the goroutine
+	concurrent := g.active.Load() != 0
+	if concurrent {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[len(servers)] = g.Shutdown(ctx)
+		}()
+	}
+	// the first server is shut down by the calling goroutine
+	first := -1
+	for i, server := range servers {
+		switch {
+		case server == nil:
+		case first < 0:
+			first = i
+		default:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = server.Shutdown(ctx)
+			}()
+		}
+	}
+	if first >= 0 {
+		errs[first] = servers[first].Shutdown(ctx)
+	}
 	wg.Wait()
+	if !concurrent {
+		errs[len(servers)] = g.Shutdown(ctx)
+	}
 
-	return errors.Join(serverErr, shutdownerErr)
+	return errors.Join(errs...)
 }
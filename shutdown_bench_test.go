@@ -30,3 +30,38 @@ func BenchmarkNewGracefulShutdown(b *testing.B) {
 		b.Fatalf("expected %d, got %d", b.N, counter)
 	}
 }
+
+func BenchmarkMiddlewareWithContextCancellation(b *testing.B) {
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	w := httptest.NewRecorder()
+
+	for _, bc := range []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{name: "context untouched", handler: func(w http.ResponseWriter, r *http.Request) {}},
+		{name: "context done", handler: func(w http.ResponseWriter, r *http.Request) { _ = r.Context().Done() }},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			shutdowner := shutdown.NewShutdowner(shutdown.WithContextCancellation())
+			handler := shutdowner.Middleware(bc.handler)
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				handler.ServeHTTP(w, req)
+			}
+		})
+	}
+}
+
+func BenchmarkShutdownWithServer(b *testing.B) {
+	b.ReportAllocs()
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		var shutdowner shutdown.Shutdowner
+		server := &http.Server{Handler: shutdowner.Middleware(http.NotFoundHandler())}
+		if err := shutdowner.ShutdownWithServer(ctx, server); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
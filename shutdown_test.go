@@ -3,11 +3,15 @@ package shutdown_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
@@ -72,78 +76,100 @@ func ExampleShutdowner() {
 
 func TestShutdowner(t *testing.T) {
 	t.Parallel()
-	newSleepingHandler := func(d time.Duration) (http.Handler, chan struct{}) {
-		started := make(chan struct{})
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			close(started)
-			time.Sleep(d)
-		}), started
-	}
+
+	// the handlers are held in the middleware until released, so the outcomes don't depend on timing
+	type release int
+	const (
+		releaseNever release = iota
+		releaseBeforeShutdown
+		releaseDuringShutdown
+	)
 
 	tt := []struct {
 		name                   string
-		durations              []time.Duration
-		shutdownAfter          time.Duration
-		shutdownTimeout        time.Duration
+		releases               []release
 		expectDeadlineExceeded bool
 	}{
 		{
 			name:                   "shutdown before handler finishes - deadline exceeded",
-			durations:              []time.Duration{10 * time.Millisecond},
-			shutdownAfter:          0,
-			shutdownTimeout:        5 * time.Millisecond,
+			releases:               []release{releaseNever},
 			expectDeadlineExceeded: true,
 		},
 		{
 			name:                   "shutdown before handler finishes - deadline not exceeded",
-			durations:              []time.Duration{5 * time.Millisecond},
-			shutdownAfter:          0,
-			shutdownTimeout:        10 * time.Millisecond,
+			releases:               []release{releaseDuringShutdown},
 			expectDeadlineExceeded: false,
 		},
 		{
 			name:                   "shutdown before multiple handlers finish - deadline exceeded",
-			durations:              []time.Duration{10 * time.Millisecond, 5 * time.Millisecond, 5 * time.Millisecond},
-			shutdownAfter:          0,
-			shutdownTimeout:        5 * time.Millisecond,
+			releases:               []release{releaseNever, releaseDuringShutdown, releaseDuringShutdown},
 			expectDeadlineExceeded: true,
 		},
 		{
 			name:                   "shutdown before multiple handlers finish - deadline not exceeded",
-			durations:              []time.Duration{5 * time.Millisecond, 5 * time.Millisecond, 5 * time.Millisecond},
-			shutdownAfter:          0,
-			shutdownTimeout:        10 * time.Millisecond,
+			releases:               []release{releaseDuringShutdown, releaseDuringShutdown, releaseDuringShutdown},
 			expectDeadlineExceeded: false,
 		},
 		{
 			name:                   "shutdown after handler finishes",
-			durations:              []time.Duration{5 * time.Millisecond},
-			shutdownAfter:          10 * time.Millisecond,
-			shutdownTimeout:        5 * time.Millisecond,
+			releases:               []release{releaseBeforeShutdown},
 			expectDeadlineExceeded: false,
 		},
 		{
 			name:                   "shutdown after multiple handlers finish",
-			durations:              []time.Duration{5 * time.Millisecond, 5 * time.Millisecond, 5 * time.Millisecond},
-			shutdownAfter:          10 * time.Millisecond,
-			shutdownTimeout:        5 * time.Millisecond,
+			releases:               []release{releaseBeforeShutdown, releaseBeforeShutdown, releaseBeforeShutdown},
 			expectDeadlineExceeded: false,
 		},
 	}
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
 			var shutdowner shutdown.Shutdowner
-
-			for _, d := range tc.durations {
-				handler, started := newSleepingHandler(d)
-				go shutdowner.Middleware(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
-				<-started // wait for handler to start
+			gates, finished := make(map[string]chan struct{}), make(map[string]chan struct{})
+			for i := range tc.releases {
+				gates[fmt.Sprint("/", i)] = make(chan struct{})
+				finished[fmt.Sprint("/", i)] = make(chan struct{})
 			}
+			started := make(chan struct{})
+			shutdown.SetTestHookServe(&shutdowner, func(r *http.Request) {
+				started <- struct{}{}
+				<-gates[r.URL.Path]
+			})
+			handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(finished[r.URL.Path])
+			}))
+			for i := range tc.releases {
+				go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", fmt.Sprint("/", i), nil))
+				<-started
+			}
+			defer func() {
+				for i, r := range tc.releases {
+					if r == releaseNever {
+						close(gates[fmt.Sprint("/", i)])
+					}
+				}
+			}()
 
-			time.Sleep(tc.shutdownAfter)
+			for i, r := range tc.releases {
+				if r == releaseBeforeShutdown {
+					close(gates[fmt.Sprint("/", i)])
+					<-finished[fmt.Sprint("/", i)]
+				}
+			}
+			shutdowner.OnShutdown(func(ctx context.Context) {
+				for i, r := range tc.releases {
+					if r == releaseDuringShutdown {
+						close(gates[fmt.Sprint("/", i)])
+					}
+				}
+			})
 
-			deadlineCtx, cancel := context.WithTimeout(context.Background(), tc.shutdownTimeout)
+			timeout := time.Second
+			if tc.expectDeadlineExceeded {
+				timeout = 5 * time.Millisecond
+			}
+			deadlineCtx, cancel := context.WithTimeout(context.Background(), timeout)
 			defer cancel()
 			err := shutdowner.Shutdown(deadlineCtx)
 			switch {
@@ -155,3 +181,653 @@ func TestShutdowner(t *testing.T) {
 		})
 	}
 }
+
+func TestShutdowner_AbandonDrain(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	go shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release // stuck until the test ends
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	<-started
+
+	// the hook runs from within Shutdown, so the drain is in progress once it was called
+	shuttingDown := make(chan struct{})
+	shutdowner.OnShutdown(func(ctx context.Context) { close(shuttingDown) })
+
+	done := make(chan error)
+	go func() { done <- shutdowner.Shutdown(context.Background()) }()
+	<-shuttingDown
+
+	if shutdowner.IsDrained() {
+		t.Errorf("expected shutdowner not to be drained while a handler is stuck")
+	}
+	shutdowner.AbandonDrain()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, shutdown.ErrAborted) {
+			t.Errorf("expected %v, but got %v", shutdown.ErrAborted, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Shutdown did not return after AbandonDrain")
+	}
+	if !shutdowner.IsDrained() {
+		t.Errorf("expected shutdowner to be drained after AbandonDrain")
+	}
+	if err := shutdowner.Shutdown(context.Background()); !errors.Is(err, shutdown.ErrAborted) {
+		t.Errorf("expected %v from subsequent Shutdown, but got %v", shutdown.ErrAborted, err)
+	}
+}
+
+func TestShutdowner_String(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithName("api"))
+	if got, want := shutdowner.String(), `shutdowner "api" (active=0 shuttingDown=false drained=false)`; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_ = shutdowner.Shutdown(ctx)
+	if got, want := fmt.Sprintf("%v", shutdowner), `shutdowner "api" (active=1 shuttingDown=true drained=false)`; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	close(release)
+	<-finished
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if got, want := shutdowner.String(), `shutdowner "api" (active=0 shuttingDown=true drained=true)`; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestWithShutdownGuard(t *testing.T) {
+	t.Parallel()
+	errMigration := errors.New("critical migration in progress")
+	var calls int
+	shutdowner := shutdown.NewShutdowner(shutdown.WithShutdownGuard(func() error {
+		calls++
+		if calls == 1 {
+			return errMigration
+		}
+		return nil
+	}))
+	server := httptest.NewServer(shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	defer server.Close()
+
+	if err := shutdowner.ShutdownWithServer(context.Background(), server.Config); !errors.Is(err, errMigration) {
+		t.Fatalf("expected %v, but got %v", errMigration, err)
+	}
+	if shutdowner.IsShuttingDown() {
+		t.Errorf("expected the guard to prevent the shutdown from beginning")
+	}
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the server to keep serving after the veto, got %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if !shutdowner.IsShuttingDown() {
+		t.Errorf("expected shutdown to have begun")
+	}
+	if err := shutdowner.BeginShutdown(); err != nil || calls != 2 {
+		t.Errorf("expected the guard not to be consulted after shutdown began, got %v after %d calls", err, calls)
+	}
+}
+
+func TestShutdowner_Shutdown_DrainErrors(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name       string
+		ctx        func() (context.Context, context.CancelFunc)
+		wantCtxErr error
+		check      func(err error) (int64, bool)
+	}{
+		{
+			name: "deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+			wantCtxErr: context.DeadlineExceeded,
+			check: func(err error) (int64, bool) {
+				var e *shutdown.DrainTimeoutError
+				if !errors.As(err, &e) {
+					return 0, false
+				}
+				return e.Remaining, true
+			},
+		},
+		{
+			name: "cancel",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			wantCtxErr: context.Canceled,
+			check: func(err error) (int64, bool) {
+				var e *shutdown.DrainCancelledError
+				if !errors.As(err, &e) {
+					return 0, false
+				}
+				return e.Remaining, true
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var shutdowner shutdown.Shutdowner
+			release := make(chan struct{})
+			defer close(release)
+			entered := make(chan struct{})
+			go shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(entered)
+				<-release
+			})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			<-entered
+
+			ctx, cancel := tc.ctx()
+			defer cancel()
+			err := shutdowner.Shutdown(ctx)
+			if !errors.Is(err, tc.wantCtxErr) {
+				t.Errorf("expected the error to wrap %v, got %v", tc.wantCtxErr, err)
+			}
+			remaining, ok := tc.check(err)
+			if !ok {
+				t.Fatalf("unexpected error type %T", err)
+			}
+			if remaining != 1 {
+				t.Errorf("expected 1 remaining handler, got %d", remaining)
+			}
+		})
+	}
+}
+
+func TestShutdowner_ShutdownStartedAt(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	if _, ok := shutdowner.ShutdownStartedAt(); ok {
+		t.Errorf("expected no start time before shutdown began")
+	}
+
+	before := time.Now()
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	after := time.Now()
+
+	startedAt, ok := shutdowner.ShutdownStartedAt()
+	if !ok {
+		t.Fatalf("expected a start time after shutdown began")
+	}
+	if startedAt.Before(before) || startedAt.After(after) {
+		t.Errorf("expected the start time to be between %v and %v, got %v", before, after, startedAt)
+	}
+
+	time.Sleep(time.Millisecond)
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if again, _ := shutdowner.ShutdownStartedAt(); !again.Equal(startedAt) {
+		t.Errorf("expected the start time to be kept, got %v instead of %v", again, startedAt)
+	}
+}
+
+func TestWithSkipCancelledRequests(t *testing.T) {
+	t.Parallel()
+
+	for _, skip := range []bool{false, true} {
+		var opts []shutdown.Option
+		if skip {
+			opts = append(opts, shutdown.WithSkipCancelledRequests())
+		}
+		shutdowner := shutdown.NewShutdowner(opts...)
+		var called bool
+		handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+
+		if called == skip {
+			t.Errorf("skip=%t: expected the handler to be called: %t", skip, !skip)
+		}
+		want := int64(1)
+		if skip {
+			want = 0
+		}
+		if got := shutdowner.Stats().Started; got != want {
+			t.Errorf("skip=%t: expected %d counted requests, got %d", skip, want, got)
+		}
+
+		called = false
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if !called {
+			t.Errorf("skip=%t: expected live requests to be served", skip)
+		}
+	}
+}
+
+func TestWithDrainPredicate(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithDrainPredicate(func(r *http.Request) bool {
+		return r.Header.Get("Upgrade") == "websocket"
+	}))
+
+	releaseWS, releaseREST := make(chan struct{}), make(chan struct{})
+	defer close(releaseREST)
+	entered := make(chan struct{}, 2)
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		if r.URL.Path == "/ws" {
+			<-releaseWS
+		} else {
+			<-releaseREST
+		}
+	}))
+	ws := httptest.NewRequest("GET", "/ws", nil)
+	ws.Header.Set("Connection", "Upgrade")
+	ws.Header.Set("Upgrade", "websocket")
+	go handler.ServeHTTP(httptest.NewRecorder(), ws)
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api", nil))
+	<-entered
+	<-entered
+
+	if got := shutdowner.ActiveCount(); got != 1 {
+		t.Errorf("expected only the websocket handler to be counted, got %d", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the drain to wait for the websocket handler, got %v", err)
+	}
+
+	close(releaseWS)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Errorf("expected the drain not to wait for the REST handler, got %v", err)
+	}
+}
+
+func TestShutdowner_ShutdownWithServer_JoinsErrors(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name  string
+		setup func(t *testing.T, g *shutdown.Shutdowner, server *httptest.Server)
+	}{
+		{
+			// no active handlers: a hook keeps the drain busy and a connection without a request the server
+			name: "fast path",
+			setup: func(t *testing.T, g *shutdown.Shutdowner, server *httptest.Server) {
+				hookRelease := make(chan struct{})
+				t.Cleanup(func() { close(hookRelease) })
+				g.OnShutdown(func(ctx context.Context) { <-hookRelease })
+				conn, err := net.Dial("tcp", server.Listener.Addr().String())
+				if err != nil {
+					t.Fatalf("failed to dial: %v", err)
+				}
+				t.Cleanup(func() { _ = conn.Close() })
+				// give the server time to pick up the connection
+				time.Sleep(10 * time.Millisecond)
+			},
+		},
+		{
+			name: "slow path",
+			setup: func(t *testing.T, g *shutdown.Shutdowner, server *httptest.Server) {
+				go func() {
+					resp, err := server.Client().Get(server.URL)
+					if err == nil {
+						resp.Body.Close()
+					}
+				}()
+				for g.ActiveCount() != 1 {
+					time.Sleep(time.Millisecond)
+				}
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var shutdowner shutdown.Shutdowner
+			release := make(chan struct{})
+			server := httptest.NewServer(shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-release
+			})))
+			defer server.Close()
+			defer close(release)
+			tc.setup(t, &shutdowner, server)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			err := shutdowner.ShutdownWithServer(ctx, server.Config)
+			joined, ok := err.(interface{ Unwrap() []error })
+			if !ok || len(joined.Unwrap()) != 2 {
+				t.Fatalf("expected the errors of the server and the shutdowner to be joined, got %v", err)
+			}
+			for _, err := range joined.Unwrap() {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithDrainTimeout(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithDrainTimeout(20 * time.Millisecond))
+	release := make(chan struct{})
+	defer close(release)
+	shutdowner.GoNamed("stuck", func(ctx context.Context) { <-release })
+
+	start := time.Now()
+	if err := shutdowner.Shutdown(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the drain timeout to bound the drain, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the drain to time out after about 20ms, took %v", elapsed)
+	}
+
+	// a deadline of the context takes precedence
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_ = shutdowner.Shutdown(ctx)
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("expected the deadline of the context to be used, returned after %v", elapsed)
+	}
+}
+
+func TestWithDrainTimeout_EntryPoints(t *testing.T) {
+	t.Parallel()
+
+	for name, shutdownFunc := range map[string]func(g *shutdown.Shutdowner) error{
+		"ShutdownWithGRPC": func(g *shutdown.Shutdowner) error {
+			return g.ShutdownWithGRPC(context.Background(), newFakeGRPCServer())
+		},
+		"ShutdownWithCallback": func(g *shutdown.Shutdowner) error {
+			return g.ShutdownWithCallback(context.Background(), func(remaining int64) {})
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			shutdowner := shutdown.NewShutdowner(shutdown.WithDrainTimeout(20 * time.Millisecond))
+			release := make(chan struct{})
+			defer close(release)
+			shutdowner.GoNamed("stuck", func(ctx context.Context) { <-release })
+
+			start := time.Now()
+			if err := shutdownFunc(shutdowner); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected the drain timeout to bound the drain, got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("expected the drain to time out after about 20ms, took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestShutdowner_BeginStateParity(t *testing.T) {
+	t.Parallel()
+
+	for name, shutdownFunc := range map[string]func(g *shutdown.Shutdowner, ctx context.Context) error{
+		"Shutdown": func(g *shutdown.Shutdowner, ctx context.Context) error { return g.Shutdown(ctx) },
+		"ShutdownWithServer": func(g *shutdown.Shutdowner, ctx context.Context) error {
+			return g.ShutdownWithServer(ctx, &http.Server{})
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			shutdowner := shutdown.NewShutdowner(shutdown.WithContextCancellation(), shutdown.WithRejectUpgradesOnly())
+
+			entered, release := make(chan struct{}), make(chan struct{})
+			cancelled := make(chan error, 1)
+			handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/slow" {
+					return
+				}
+				close(entered)
+				<-r.Context().Done()
+				cancelled <- context.Cause(r.Context())
+				<-release
+			}))
+			handlerDone := make(chan struct{})
+			go func() {
+				defer close(handlerDone)
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+			}()
+			<-entered
+
+			shutdownDone := make(chan error, 1)
+			go func() { shutdownDone <- shutdownFunc(shutdowner, context.Background()) }()
+
+			if cause := <-cancelled; !errors.Is(cause, shutdown.ErrShuttingDown) {
+				t.Errorf("expected the request context to be cancelled with %v, got %v", shutdown.ErrShuttingDown, cause)
+			}
+			if !shutdowner.IsShuttingDown() {
+				t.Errorf("expected shutdown to have begun")
+			}
+			if _, ok := shutdowner.ShutdownStartedAt(); !ok {
+				t.Errorf("expected the start of the shutdown to be recorded")
+			}
+			select {
+			case <-shutdowner.Done():
+			default:
+				t.Errorf("expected the Done channel to be closed")
+			}
+			if err := shutdowner.ShutdownContext().Err(); err == nil {
+				t.Errorf("expected the shutdown context to be cancelled")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, newUpgradeRequest())
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Errorf("expected upgrade requests to be rejected, got status %d", rec.Code)
+			}
+
+			close(release)
+			<-handlerDone
+			if err := <-shutdownDone; err != nil {
+				t.Fatalf("no error expected but got %v", err)
+			}
+		})
+	}
+}
+
+func TestShutdowner_ConcurrentShutdown(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	go shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	<-entered
+
+	const callers = 8
+	shutdownAll := func(timeout time.Duration) []error {
+		errs := make(chan error, callers)
+		for range callers {
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+				errs <- shutdowner.Shutdown(ctx)
+			}()
+		}
+		results := make([]error, callers)
+		for i := range results {
+			results[i] = <-errs
+		}
+		return results
+	}
+
+	for _, err := range shutdownAll(10 * time.Millisecond) {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected all callers to time out, got %v", err)
+		}
+	}
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		close(release)
+	}()
+	for _, err := range shutdownAll(time.Second) {
+		if err != nil {
+			t.Errorf("expected all callers to see the drain complete, got %v", err)
+		}
+	}
+	if !shutdowner.IsDrained() {
+		t.Errorf("expected the shutdowner to be drained")
+	}
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Errorf("expected later calls to return nil, got %v", err)
+	}
+}
+
+func TestShutdowner_ShutdownUnderLoad(t *testing.T) {
+	t.Parallel()
+
+	for range 50 {
+		var shutdowner shutdown.Shutdowner
+		handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		// requests keep arriving while shutdown begins, so the counter repeatedly drops to zero and increases again
+		// while Shutdown waits
+		var load sync.WaitGroup
+		for range 8 {
+			load.Add(1)
+			go func() {
+				defer load.Done()
+				for range 50 {
+					handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+				}
+			}()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := shutdowner.Shutdown(ctx); err != nil {
+			t.Errorf("no error expected but got %v", err)
+		}
+		cancel()
+		load.Wait()
+	}
+}
+
+func TestShutdowner_ShutdownWithServers(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	// the handler of the app server is not tracked, so only the server's shutdown fails
+	stuck, entered := make(chan struct{}), make(chan struct{})
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-stuck
+	}))
+	defer app.Close()
+	defer close(stuck)
+	admin := httptest.NewServer(shutdowner.Middleware(http.NotFoundHandler()))
+	defer admin.Close()
+	go func() {
+		resp, err := app.Client().Get(app.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := shutdowner.ShutdownWithServers(ctx, app.Config, nil, admin.Config)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the error of the app server, got %v", err)
+	}
+	if !shutdowner.IsDrained() {
+		t.Errorf("expected the shutdowner to be drained")
+	}
+	if _, err := admin.Client().Get(admin.URL); err == nil {
+		t.Errorf("expected the admin server to be shut down")
+	}
+}
+
+func TestShutdowner_ShutdownWithServer_BeginsFirst(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+	var hookCalled atomic.Bool
+	shutdowner.OnShutdown(func(ctx context.Context) { hookCalled.Store(true) })
+
+	server := &http.Server{Handler: shutdowner.Middleware(http.NotFoundHandler())}
+	// the callbacks registered with the server run once its Shutdown method is invoked
+	type beginState struct{ shuttingDown, cancelled bool }
+	observed := make(chan beginState, 1)
+	server.RegisterOnShutdown(func() {
+		observed <- beginState{shuttingDown: shutdowner.IsShuttingDown(),
+			cancelled: shutdowner.ShutdownContext().Err() != nil}
+	})
+
+	if err := shutdowner.ShutdownWithServer(context.Background(), server); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if state := <-observed; !state.shuttingDown || !state.cancelled {
+		t.Errorf("expected shutdown to have begun before the server was shut down, got %+v", state)
+	}
+	if !hookCalled.Load() {
+		t.Errorf("expected the OnShutdown hook to be called")
+	}
+}
+
+func TestShutdowner_Wait(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	go shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	<-entered
+
+	waited := make(chan struct{})
+	go func() {
+		shutdowner.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatalf("expected Wait to block while the handler is active")
+	case <-time.After(5 * time.Millisecond):
+	}
+	close(release)
+	<-waited
+
+	if shutdowner.IsShuttingDown() {
+		t.Errorf("expected Wait not to begin the shutdown")
+	}
+	shutdowner.Wait()
+}
@@ -0,0 +1,42 @@
+// Package shutdownecho adapts a shutdown.Shutdowner to the echo web framework. It is a module of its own, so that
+// applications not using echo don't depend on it.
+package shutdownecho
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+// Middleware returns an echo middleware that tracks the handlers like g.Middleware, so that Shutdown waits for them to
+// return, and returns the error of the wrapped handler to echo. Requests rejected by g, e.g. because of
+// shutdown.WithRejectUpgradesOnly, are answered without calling the handler. The request passed to the handler carries
+// the context injected by g, e.g. the context cancelled at shutdown with shutdown.WithContextCancellation.
+func Middleware(g *shutdown.Shutdowner) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			h := &handler{c: c, next: next}
+			res := c.Response()
+			g.Middleware(h).ServeHTTP(res.Writer, c.Request())
+			return h.err
+		}
+	}
+}
+
+// handler calls an echo handler as an http.Handler and records its error.
+type handler struct {
+	c    echo.Context
+	next echo.HandlerFunc
+	err  error
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the middleware may wrap the writer, e.g. for shutdown.WithHijackTracking, so the handler must write through it
+	res := h.c.Response()
+	orig := res.Writer
+	res.Writer = w
+	defer func() { res.Writer = orig }()
+	h.c.SetRequest(r)
+	h.err = h.next(h.c)
+}
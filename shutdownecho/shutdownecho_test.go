@@ -0,0 +1,78 @@
+package shutdownecho_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	shutdown "github.com/mheck136/ws-shutdown"
+	"github.com/mheck136/ws-shutdown/shutdownecho"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithContextCancellation(), shutdown.WithRejectUpgradesOnly())
+
+	e := echo.New()
+	e.Use(shutdownecho.Middleware(shutdowner))
+	entered, release := make(chan struct{}), make(chan struct{})
+	e.GET("/slow", func(c echo.Context) error {
+		close(entered)
+		<-c.Request().Context().Done()
+		<-release
+		return c.String(http.StatusOK, "drained")
+	})
+	errTeapot := echo.NewHTTPError(http.StatusTeapot, "teapot")
+	e.GET("/error", func(c echo.Context) error {
+		return errTeapot
+	})
+	e.GET("/ws", func(c echo.Context) error {
+		t.Errorf("expected the upgrade request to be rejected")
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/error", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("expected the handler error to be returned to echo, got status %d", rec.Code)
+	}
+
+	slow := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.ServeHTTP(slow, httptest.NewRequest("GET", "/slow", nil))
+	}()
+	<-entered
+	if got := shutdowner.ActiveCount(); got != 1 {
+		t.Errorf("expected 1 tracked handler, got %d", got)
+	}
+
+	shutdownDone := make(chan error, 1)
+	go func() { shutdownDone <- shutdowner.Shutdown(context.Background()) }()
+	<-shutdowner.Done()
+
+	upgrade := httptest.NewRequest("GET", "/ws", nil)
+	upgrade.Header.Set("Connection", "Upgrade")
+	upgrade.Header.Set("Upgrade", "websocket")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, upgrade)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	close(release)
+	<-done
+	if err := <-shutdownDone; err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if slow.Code != http.StatusOK || slow.Body.String() != "drained" {
+		t.Errorf("expected the slow handler to complete, got status %d and body %q", slow.Code, slow.Body.String())
+	}
+	if !errors.Is(shutdowner.ShutdownContext().Err(), context.Canceled) {
+		t.Errorf("expected the shutdown context to be cancelled")
+	}
+}
@@ -0,0 +1,110 @@
+// Package shutdowntest provides helpers for testing the shutdown behavior of handlers wrapped with a
+// shutdown.Shutdowner.
+package shutdowntest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+// AssertDrainsWithin calls Shutdown on g with a timeout of d and fails the test if the handlers do not drain in time.
+// The failure message includes the number of handlers that were still active and their paths, the latter requiring
+// shutdown.WithTracking.
+func AssertDrainsWithin(t testing.TB, g *shutdown.Shutdowner, d time.Duration) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	if err := g.Shutdown(ctx); err != nil {
+		t.Errorf("shutdowner did not drain within %v: %v (active handlers: %d, active paths: %v)",
+			d, err, g.ActiveCount(), g.ActivePaths())
+	}
+}
+
+// AssertNoAcceptAfterShutdown sends a probe request through g.Middleware and fails the test if it reaches the wrapped
+// handler, i.e. if g still accepts new requests. Shutdown of g must have begun, and g must be configured to reject new
+// requests, e.g. with shutdown.WithRejectNewRequests.
+func AssertNoAcceptAfterShutdown(t testing.TB, g *shutdown.Shutdowner) {
+	t.Helper()
+	if !g.IsShuttingDown() {
+		t.Errorf("shutdown of the shutdowner has not begun")
+		return
+	}
+	var accepted bool
+	rec := httptest.NewRecorder()
+	g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted = true
+	})).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if accepted {
+		t.Errorf("shutdowner accepted a new request after shutdown began (status %d)", rec.Code)
+	}
+}
+
+// Scenario describes a drain run by RunScenario.
+type Scenario struct {
+	// Durations are the times the handlers take, one request being served per duration.
+	Durations []time.Duration
+	// Handler serves each request once its duration has elapsed. If nil, the requests are answered with 200 OK.
+	Handler http.Handler
+	// ShutdownAfter is the time after all requests have entered the middleware at which Shutdown is called.
+	ShutdownAfter time.Duration
+	// Timeout bounds the drain.
+	Timeout time.Duration
+}
+
+// RunScenario serves the requests of s through g.Middleware, calls Shutdown on g once s.ShutdownAfter has elapsed and
+// returns its error, which can be checked with AssertClean or AssertDeadlineExceeded. The requests still being served
+// when RunScenario returns are waited for when the test finishes.
+func RunScenario(t testing.TB, g *shutdown.Shutdowner, s Scenario) error {
+	t.Helper()
+	handler := s.Handler
+	if handler == nil {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	}
+
+	entered := make(chan struct{}, len(s.Durations))
+	var served sync.WaitGroup
+	t.Cleanup(served.Wait)
+	for i, d := range s.Durations {
+		served.Add(1)
+		go func() {
+			defer served.Done()
+			g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				entered <- struct{}{}
+				time.Sleep(d)
+				handler.ServeHTTP(w, r)
+			})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", fmt.Sprint("/", i), nil))
+		}()
+	}
+	for range s.Durations {
+		<-entered
+	}
+
+	time.Sleep(s.ShutdownAfter)
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+	return g.Shutdown(ctx)
+}
+
+// AssertClean fails the test if err, as returned by RunScenario, reports that the drain did not complete.
+func AssertClean(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Errorf("expected the drain to complete, got %v", err)
+	}
+}
+
+// AssertDeadlineExceeded fails the test unless err, as returned by RunScenario, reports that the drain timed out.
+func AssertDeadlineExceeded(t testing.TB, err error) {
+	t.Helper()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the drain to time out, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package shutdowntest_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+	"github.com/mheck136/ws-shutdown/shutdowntest"
+)
+
+// fakeT records failures instead of failing the surrounding test.
+type fakeT struct {
+	testing.TB
+	failures []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func startHandler(g *shutdown.Shutdowner, path string, release <-chan struct{}) {
+	started := make(chan struct{})
+	go g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	<-started
+}
+
+func TestAssertDrainsWithin(t *testing.T) {
+	t.Parallel()
+
+	t.Run("drains", func(t *testing.T) {
+		t.Parallel()
+		g := shutdown.NewShutdowner(shutdown.WithTracking())
+		release := make(chan struct{})
+		startHandler(g, "/ws", release)
+		close(release)
+
+		ft := &fakeT{TB: t}
+		shutdowntest.AssertDrainsWithin(ft, g, time.Second)
+		if len(ft.failures) != 0 {
+			t.Errorf("expected no failures, got %v", ft.failures)
+		}
+	})
+
+	t.Run("does not drain", func(t *testing.T) {
+		t.Parallel()
+		g := shutdown.NewShutdowner(shutdown.WithTracking())
+		release := make(chan struct{})
+		defer close(release)
+		startHandler(g, "/ws", release)
+
+		ft := &fakeT{TB: t}
+		shutdowntest.AssertDrainsWithin(ft, g, 5*time.Millisecond)
+		if len(ft.failures) != 1 {
+			t.Fatalf("expected a single failure, got %v", ft.failures)
+		}
+		for _, want := range []string{"active handlers: 1", "[/ws]"} {
+			if !strings.Contains(ft.failures[0], want) {
+				t.Errorf("expected failure %q to contain %q", ft.failures[0], want)
+			}
+		}
+	})
+}
+
+func TestAssertNoAcceptAfterShutdown(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name         string
+		opts         []shutdown.Option
+		begin        bool
+		wantFailures []string
+	}{
+		{name: "rejected", opts: []shutdown.Option{shutdown.WithRejectNewRequests()}, begin: true},
+		{name: "accepted", begin: true, wantFailures: []string{"accepted a new request"}},
+		{
+			name:         "shutdown not begun",
+			opts:         []shutdown.Option{shutdown.WithRejectNewRequests()},
+			wantFailures: []string{"has not begun"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			g := shutdown.NewShutdowner(tc.opts...)
+			if tc.begin {
+				_ = g.BeginShutdown()
+			}
+
+			ft := &fakeT{TB: t}
+			shutdowntest.AssertNoAcceptAfterShutdown(ft, g)
+			if len(ft.failures) != len(tc.wantFailures) {
+				t.Fatalf("expected failures %v, got %v", tc.wantFailures, ft.failures)
+			}
+			for i, want := range tc.wantFailures {
+				if !strings.Contains(ft.failures[i], want) {
+					t.Errorf("expected failure %q to contain %q", ft.failures[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunScenario(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name                   string
+		scenario               shutdowntest.Scenario
+		expectDeadlineExceeded bool
+	}{
+		{
+			name: "shutdown before handler finishes - deadline exceeded",
+			scenario: shutdowntest.Scenario{Durations: []time.Duration{300 * time.Millisecond},
+				Timeout: 10 * time.Millisecond},
+			expectDeadlineExceeded: true,
+		},
+		{
+			name: "shutdown before handler finishes - deadline not exceeded",
+			scenario: shutdowntest.Scenario{Durations: []time.Duration{20 * time.Millisecond},
+				Timeout: time.Second},
+		},
+		{
+			name: "shutdown before multiple handlers finish - deadline exceeded",
+			scenario: shutdowntest.Scenario{
+				Durations: []time.Duration{300 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond},
+				Timeout:   50 * time.Millisecond,
+			},
+			expectDeadlineExceeded: true,
+		},
+		{
+			name: "shutdown before multiple handlers finish - deadline not exceeded",
+			scenario: shutdowntest.Scenario{
+				Durations: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond},
+				Timeout:   time.Second,
+			},
+		},
+		{
+			name: "shutdown after handler finishes",
+			scenario: shutdowntest.Scenario{Durations: []time.Duration{0}, ShutdownAfter: 20 * time.Millisecond,
+				Timeout: 10 * time.Millisecond},
+		},
+		{
+			name: "shutdown after multiple handlers finish",
+			scenario: shutdowntest.Scenario{Durations: []time.Duration{0, 0, 0}, ShutdownAfter: 20 * time.Millisecond,
+				Timeout: 10 * time.Millisecond},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := shutdowntest.RunScenario(t, shutdown.NewShutdowner(), tc.scenario)
+			assert, opposite := shutdowntest.AssertClean, shutdowntest.AssertDeadlineExceeded
+			if tc.expectDeadlineExceeded {
+				assert, opposite = opposite, assert
+			}
+			ft := &fakeT{TB: t}
+			assert(ft, err)
+			if len(ft.failures) != 0 {
+				t.Errorf("expected no failures, got %v", ft.failures)
+			}
+			ft = &fakeT{TB: t}
+			opposite(ft, err)
+			if len(ft.failures) != 1 {
+				t.Errorf("expected a single failure of the opposite assertion, got %v", ft.failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/mheck136/ws-shutdown/shutdownwebsocket
+
+go 1.23.0
+
+require (
+	github.com/mheck136/ws-shutdown v0.0.0
+	golang.org/x/net v0.40.0
+)
+
+replace github.com/mheck136/ws-shutdown => ../
@@ -0,0 +1,25 @@
+// Package shutdownwebsocket adapts a shutdown.Shutdowner to the golang.org/x/net/websocket package. It is a module of
+// its own, so that applications not using that package don't depend on it.
+package shutdownwebsocket
+
+import (
+	"context"
+	"net/http"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+	"golang.org/x/net/websocket"
+)
+
+// Handler returns an http.Handler serving websocket connections with h that is wrapped with g.Middleware, so that
+// Shutdown waits for h to return. Each connection is tracked with g.TrackConn, which makes it subject to
+// shutdown.WithForceCloseOnTimeout, and closed with a close frame when shutdown begins, so that h returns once its
+// pending read fails.
+func Handler(g *shutdown.Shutdowner, h websocket.Handler) http.Handler {
+	return g.Middleware(websocket.Handler(func(ws *websocket.Conn) {
+		conn := g.TrackConn(ws)
+		defer conn.Close()
+		stop := context.AfterFunc(g.ShutdownContext(), func() { _ = conn.Close() })
+		defer stop()
+		h(ws)
+	}))
+}
@@ -0,0 +1,54 @@
+package shutdownwebsocket_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+	"github.com/mheck136/ws-shutdown/shutdownwebsocket"
+	"golang.org/x/net/websocket"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+
+	returned := make(chan struct{})
+	server := httptest.NewServer(shutdownwebsocket.Handler(&shutdowner, func(ws *websocket.Conn) {
+		defer close(returned)
+		_, _ = io.Copy(ws, ws)
+	}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	client, err := websocket.Dial(url, "", server.URL)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+	if err := websocket.Message.Send(client, "hello"); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	var msg string
+	if err := websocket.Message.Receive(client, &msg); err != nil || msg != "hello" {
+		t.Fatalf("expected the message to be echoed, got %q, %v", msg, err)
+	}
+	if got := shutdowner.ActiveCount(); got != 1 {
+		t.Errorf("expected 1 tracked handler, got %d", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	<-returned
+	if err := websocket.Message.Receive(client, &msg); !errors.Is(err, io.EOF) {
+		t.Errorf("expected the connection to be closed by the server, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// testHookSignalsNotified is called by RunUntilSignalWithForce once it is subscribed to the signals, if set.
+var testHookSignalsNotified func()
+
+// RunUntilSignalWithForce blocks until SIGINT or SIGTERM is received or ctx is done, and then shuts down server and the
+// Shutdowner gracefully like ShutdownWithServer, bounded by the timeout configured with WithDrainTimeout, if any. The
+// server is expected to be serving in another goroutine.
+//
+// A second signal received during the graceful shutdown forces the shutdown: the server is closed with Close, which
+// closes its listeners and connections, the connections tracked with TrackConn are closed as well, and
+// ErrForcedShutdown is returned without waiting for the remaining handlers. If the guard configured with
+// WithShutdownGuard vetoes the shutdown, the guard's error is returned and the server keeps running.
+func (g *Shutdowner) RunUntilSignalWithForce(ctx context.Context, server *http.Server) error {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	if testHookSignalsNotified != nil {
+		testHookSignalsNotified()
+	}
+
+	var cause error
+	select {
+	case sig := <-signals:
+		cause = signalError{sig}
+	case <-ctx.Done():
+		cause = context.Cause(ctx)
+	}
+
+	drainCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	drainCtx, cancelTimeout := g.withDrainTimeout(drainCtx)
+	defer cancelTimeout()
+	if err := g.begin(drainCtx, cause, true); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- g.ShutdownWithServer(drainCtx, server)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-signals:
+	}
+
+	g.log(slog.LevelWarn, "forcing shutdown on second signal", slog.Int64("active", g.active.Load()))
+	cancel()
+	_ = server.Close()
+	g.closeTrackedConns()
+	<-done
+	return ErrForcedShutdown
+}
+
+// signalError is the shutdown cause recorded by RunUntilSignalWithForce for a received signal.
+type signalError struct {
+	sig os.Signal
+}
+
+func (e signalError) Error() string {
+	return e.sig.String() + " received"
+}
+
+// Serve runs server.ListenAndServe until one of the signals is received, SIGINT or SIGTERM if none are given, and then
+// shuts down the server and the Shutdowner like ShutdownWithServer, bounded by timeout. A zero or negative timeout
+// leaves the shutdown bounded by the timeout configured with WithDrainTimeout, if any. It returns the errors of the
+// shutdown and of ListenAndServe joined with errors.Join, where http.ErrServerClosed is not considered an error. If
+// ListenAndServe fails before a signal is received, e.g. because the address is in use, its error is returned right
+// away. If the guard configured with WithShutdownGuard vetoes the shutdown, the guard's error is returned and the
+// server keeps running.
+func (g *Shutdowner) Serve(server *http.Server, timeout time.Duration, signals ...os.Signal) error {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	defer signal.Stop(received)
+	if testHookSignalsNotified != nil {
+		testHookSignalsNotified()
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- serverError(server.ListenAndServe())
+	}()
+	var cause error
+	select {
+	case err := <-served:
+		return err
+	case sig := <-received:
+		cause = signalError{sig}
+	}
+
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+	ctx, cancelTimeout := g.withDrainTimeout(ctx)
+	defer cancelTimeout()
+	if err := g.begin(ctx, cause, true); err != nil {
+		return err
+	}
+	err := g.ShutdownWithServer(ctx, server)
+	return errors.Join(err, <-served)
+}
+
+// serverError returns the error returned by a Serve method of http.Server, or nil for http.ErrServerClosed.
+func serverError(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
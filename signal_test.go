@@ -0,0 +1,196 @@
+//go:build unix
+
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+// runUntilSignal runs RunUntilSignalWithForce in a goroutine and returns once it is subscribed to the signals. The
+// tests using it must not run in parallel, since signals are process wide.
+func runUntilSignal(t *testing.T, ctx context.Context, g *shutdown.Shutdowner, server *http.Server) <-chan error {
+	t.Helper()
+	notified := make(chan struct{})
+	restore := shutdown.SetTestHookSignalsNotified(func() { close(notified) })
+	t.Cleanup(restore)
+
+	res := make(chan error, 1)
+	go func() {
+		res <- g.RunUntilSignalWithForce(ctx, server)
+	}()
+	<-notified
+	return res
+}
+
+func startServer(t *testing.T, handler http.Handler) (*http.Server, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &http.Server{Handler: handler}
+	go func() { _ = server.Serve(l) }()
+	return server, "http://" + l.Addr().String()
+}
+
+func TestRunUntilSignalWithForce_SecondSignalForces(t *testing.T) {
+	const drainTimeout = 5 * time.Second
+	shutdowner := shutdown.NewShutdowner(shutdown.WithDrainTimeout(drainTimeout))
+
+	entered := make(chan struct{})
+	server, url := startServer(t, shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-r.Context().Done() // only returns once the server closes the connection
+	})))
+	go func() {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-entered
+
+	res := runUntilSignal(t, context.Background(), shutdowner, server)
+	start := time.Now()
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatalf("failed to send signal: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if !shutdowner.IsShuttingDown() {
+		t.Errorf("expected the first signal to begin the shutdown")
+	}
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to send signal: %v", err)
+	}
+
+	select {
+	case err := <-res:
+		if !errors.Is(err, shutdown.ErrForcedShutdown) {
+			t.Errorf("expected %v, got %v", shutdown.ErrForcedShutdown, err)
+		}
+		if elapsed := time.Since(start); elapsed >= drainTimeout {
+			t.Errorf("expected the forced shutdown to return before the drain timeout, took %s", elapsed)
+		}
+	case <-time.After(drainTimeout):
+		t.Fatalf("expected the second signal to force the shutdown")
+	}
+}
+
+func TestRunUntilSignalWithForce_Graceful(t *testing.T) {
+	shutdowner := shutdown.NewShutdowner()
+	server, _ := startServer(t, shutdowner.Middleware(http.NotFoundHandler()))
+
+	res := runUntilSignal(t, context.Background(), shutdowner, server)
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to send signal: %v", err)
+	}
+	if err := <-res; err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+	if !shutdowner.IsDrained() {
+		t.Errorf("expected the shutdowner to be drained")
+	}
+	if r, _ := shutdowner.Report(); r.Cause == nil || r.Cause.Error() != "terminated received" {
+		t.Errorf("expected the signal as cause, got %v", r.Cause)
+	}
+}
+
+func TestRunUntilSignalWithForce_ContextDone(t *testing.T) {
+	shutdowner := shutdown.NewShutdowner()
+	server := httptest.NewServer(shutdowner.Middleware(http.NotFoundHandler()))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	res := runUntilSignal(t, ctx, shutdowner, server.Config)
+	cancel()
+	if err := <-res; err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+	if !shutdowner.IsShuttingDown() {
+		t.Errorf("expected the shutdown to have begun")
+	}
+}
+
+func TestRunUntilSignalWithForce_Vetoed(t *testing.T) {
+	veto := errors.New("not yet")
+	shutdowner := shutdown.NewShutdowner(shutdown.WithShutdownGuard(func() error { return veto }))
+	server, _ := startServer(t, shutdowner.Middleware(http.NotFoundHandler()))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	res := runUntilSignal(t, ctx, shutdowner, server)
+	cancel()
+	if err := <-res; !errors.Is(err, veto) {
+		t.Errorf("expected the guard error, got %v", err)
+	}
+}
+
+// serveUntilSignal runs Serve in a goroutine and returns once it is subscribed to the signals. Like runUntilSignal, the
+// tests using it must not run in parallel.
+func serveUntilSignal(t *testing.T, g *shutdown.Shutdowner, server *http.Server, signals ...os.Signal) <-chan error {
+	t.Helper()
+	notified := make(chan struct{})
+	restore := shutdown.SetTestHookSignalsNotified(func() { close(notified) })
+	t.Cleanup(restore)
+
+	res := make(chan error, 1)
+	go func() {
+		res <- g.Serve(server, time.Second, signals...)
+	}()
+	<-notified
+	return res
+}
+
+func TestShutdowner_Serve(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		signals []os.Signal
+		send    syscall.Signal
+	}{
+		{name: "default signals", send: syscall.SIGTERM},
+		{name: "custom signal", signals: []os.Signal{syscall.SIGHUP}, send: syscall.SIGHUP},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			shutdowner := shutdown.NewShutdowner()
+			server := &http.Server{Addr: "127.0.0.1:0", Handler: shutdowner.Middleware(http.NotFoundHandler())}
+
+			res := serveUntilSignal(t, shutdowner, server, tc.signals...)
+			if err := syscall.Kill(syscall.Getpid(), tc.send); err != nil {
+				t.Fatalf("failed to send signal: %v", err)
+			}
+			if err := <-res; err != nil {
+				t.Errorf("no error expected but got %v", err)
+			}
+			if !shutdowner.IsDrained() {
+				t.Errorf("expected the shutdowner to be drained")
+			}
+		})
+	}
+}
+
+func TestShutdowner_Serve_ListenError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+	shutdowner := shutdown.NewShutdowner()
+	server := &http.Server{Addr: l.Addr().String(), Handler: shutdowner.Middleware(http.NotFoundHandler())}
+
+	if err := <-serveUntilSignal(t, shutdowner, server); err == nil {
+		t.Errorf("expected the error of ListenAndServe for an address in use")
+	}
+	if shutdowner.IsShuttingDown() {
+		t.Errorf("expected the shutdown not to begin")
+	}
+}
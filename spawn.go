@@ -0,0 +1,35 @@
+package shutdown
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// spawnKey is the context key under which Middleware stores the number of goroutines spawned by a request with
+// SpawnTracked if WithMaxSpawnPerRequest is configured.
+type spawnKey struct{}
+
+// WithMaxSpawnPerRequest configures the maximum number of goroutines a single request may spawn with SpawnTracked,
+// so that a buggy handler cannot create an unbounded amount of tracked work that never drains. Counting the spawns
+// costs an additional context per request, so there is no limit by default.
+func WithMaxSpawnPerRequest(n int) Option {
+	return func(g *Shutdowner) {
+		g.cfg.maxSpawn = n
+	}
+}
+
+// SpawnTracked runs fn in a new goroutine that Shutdown waits for, e.g. for work a handler detaches from its request.
+// ctx is typically the request context; fn is passed a context that keeps its values, but is not cancelled when the
+// request ends, only when shutdown begins. If ctx belongs to a request that has already spawned as many goroutines as
+// configured with WithMaxSpawnPerRequest, fn is not run and ErrSpawnLimitExceeded is returned.
+func (g *Shutdowner) SpawnTracked(ctx context.Context, fn func(ctx context.Context)) error {
+	if spawned, ok := ctx.Value(spawnKey{}).(*atomic.Int32); ok && int(spawned.Add(1)) > g.cfg.maxSpawn {
+		return ErrSpawnLimitExceeded
+	}
+	subCtx, release := g.SubContext(context.WithoutCancel(ctx))
+	go func() {
+		defer release()
+		fn(subCtx)
+	}()
+	return nil
+}
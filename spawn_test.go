@@ -0,0 +1,66 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_SpawnTracked(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithMaxSpawnPerRequest(2))
+
+	release := make(chan struct{})
+	spawned := make(chan context.Context, 3)
+	var errs []error
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for range 3 {
+			errs = append(errs, shutdowner.SpawnTracked(r.Context(), func(ctx context.Context) {
+				spawned <- ctx
+				<-release
+			}))
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	for i, want := range []error{nil, nil, shutdown.ErrSpawnLimitExceeded} {
+		if !errors.Is(errs[i], want) {
+			t.Errorf("spawn %d: expected %v, got %v", i, want, errs[i])
+		}
+	}
+	ctx := <-spawned
+	<-spawned
+	if err := ctx.Err(); err != nil {
+		t.Errorf("expected the spawned context to outlive the request, got %v", err)
+	}
+
+	// the limit is per request
+	var err error
+	shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err = shutdowner.SpawnTracked(r.Context(), func(ctx context.Context) {})
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Errorf("expected another request to be able to spawn, got %v", err)
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := shutdowner.Shutdown(drainCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the drain to wait for the spawned goroutines, got %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Errorf("expected the spawned context to be cancelled at shutdown")
+	}
+
+	close(release)
+	drainCtx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(drainCtx); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+}
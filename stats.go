@@ -0,0 +1,97 @@
+package shutdown
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Stats holds the counters of the handlers wrapped with Middleware.
+type Stats struct {
+	// Active is the number of handlers that are currently running.
+	Active int64
+	// Started is the number of handlers that have been started.
+	Started int64
+	// Finished is the number of handlers that have returned.
+	Finished int64
+	// Rejected is the number of requests that have been rejected because shutdown had begun.
+	Rejected int64
+}
+
+// counters are the atomic counters backing Stats.
+type counters struct {
+	active   atomic.Int64
+	started  atomic.Int64
+	finished atomic.Int64
+	rejected atomic.Int64
+}
+
+func (c *counters) stats() Stats {
+	return Stats{
+		Active:   c.active.Load(),
+		Started:  c.started.Load(),
+		Finished: c.finished.Load(),
+		Rejected: c.rejected.Load(),
+	}
+}
+
+// WithClassifier configures the function that assigns each request a traffic class, e.g. "websocket" or "rest", for
+// which ClassStats maintains separate counters. The number of distinct classes should be small.
+func WithClassifier(classify func(r *http.Request) string) Option {
+	return func(g *Shutdowner) {
+		g.cfg.classify = classify
+	}
+}
+
+// Stats returns the counters of all handlers wrapped with Middleware.
+func (g *Shutdowner) Stats() Stats {
+	return Stats{
+		Active:   g.active.Load(),
+		Started:  g.started.Load(),
+		Finished: g.finished.Load(),
+		Rejected: g.rejected.Load(),
+	}
+}
+
+// SnapshotAndReset returns the counters like Stats, but with Started, Finished and Rejected counted since the previous
+// call, for exporters that report deltas. Active is a gauge and is returned as is. The cumulative counters returned by
+// Stats, and used for the Report, are not affected. No increment is lost or reported twice across calls, but the
+// returned counters are not a consistent snapshot of a single instant with respect to each other. Per-class counters
+// are not included.
+func (g *Shutdowner) SnapshotAndReset() Stats {
+	g.deltaMu.Lock()
+	defer g.deltaMu.Unlock()
+	s := g.Stats()
+	delta := Stats{
+		Active:   s.Active,
+		Started:  s.Started - g.deltaBase.Started,
+		Finished: s.Finished - g.deltaBase.Finished,
+		Rejected: s.Rejected - g.deltaBase.Rejected,
+	}
+	g.deltaBase = s
+	return delta
+}
+
+// ClassStats returns the counters per traffic class as assigned by the function configured with WithClassifier, so
+// that dashboards can show which class of traffic is slow to drain. It returns an empty map if no classifier is
+// configured.
+func (g *Shutdowner) ClassStats() map[string]Stats {
+	stats := make(map[string]Stats)
+	g.classes.Range(func(class, c any) bool {
+		stats[class.(string)] = c.(*counters).stats()
+		return true
+	})
+	return stats
+}
+
+// classCounters returns the counters of the class of r, or nil if no classifier is configured.
+func (g *Shutdowner) classCounters(r *http.Request) *counters {
+	if g.cfg.classify == nil {
+		return nil
+	}
+	class := g.cfg.classify(r)
+	if c, ok := g.classes.Load(class); ok {
+		return c.(*counters)
+	}
+	c, _ := g.classes.LoadOrStore(class, &counters{})
+	return c.(*counters)
+}
@@ -0,0 +1,155 @@
+package shutdown_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_ClassStats(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(
+		shutdown.WithRejectUpgradesOnly(),
+		shutdown.WithClassifier(func(r *http.Request) string {
+			if r.Header.Get("Upgrade") != "" {
+				return "websocket"
+			}
+			return "rest"
+		}),
+	)
+
+	release := make(chan struct{})
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			<-release
+		}
+	}))
+
+	// two long-lived websocket handlers and three finished rest requests
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	for range 2 {
+		go func() {
+			started <- struct{}{}
+			handler.ServeHTTP(httptest.NewRecorder(), newUpgradeRequest())
+			finished <- struct{}{}
+		}()
+		<-started
+	}
+	for range 3 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	for shutdowner.ClassStats()["websocket"].Active != 2 {
+		time.Sleep(time.Millisecond) // wait for the websocket handlers to enter the middleware
+	}
+
+	// a websocket upgrade arriving after shutdown began is rejected
+	shutdowner.BeginShutdown()
+	handler.ServeHTTP(httptest.NewRecorder(), newUpgradeRequest())
+
+	stats := shutdowner.ClassStats()
+	if want := (shutdown.Stats{Active: 2, Started: 2, Rejected: 1}); stats["websocket"] != want {
+		t.Errorf("expected websocket stats %+v, got %+v", want, stats["websocket"])
+	}
+	if want := (shutdown.Stats{Started: 3, Finished: 3}); stats["rest"] != want {
+		t.Errorf("expected rest stats %+v, got %+v", want, stats["rest"])
+	}
+	if want := (shutdown.Stats{Active: 2, Started: 5, Finished: 3, Rejected: 1}); shutdowner.Stats() != want {
+		t.Errorf("expected overall stats %+v, got %+v", want, shutdowner.Stats())
+	}
+
+	close(release)
+	<-finished
+	<-finished
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if want := (shutdown.Stats{Started: 2, Finished: 2, Rejected: 1}); shutdowner.ClassStats()["websocket"] != want {
+		t.Errorf("expected websocket stats %+v, got %+v", want, shutdowner.ClassStats()["websocket"])
+	}
+}
+
+func TestShutdowner_SnapshotAndReset(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithRejectUpgradesOnly())
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	release := make(chan struct{})
+	started, finished := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(finished)
+		shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	<-started
+	for range 3 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	_ = shutdowner.BeginShutdown()
+	handler.ServeHTTP(httptest.NewRecorder(), newUpgradeRequest())
+
+	if want, got := (shutdown.Stats{Active: 1, Started: 4, Finished: 3, Rejected: 1}), shutdowner.SnapshotAndReset(); got != want {
+		t.Errorf("expected snapshot %+v, got %+v", want, got)
+	}
+	if want, got := (shutdown.Stats{Active: 1, Started: 4, Finished: 3, Rejected: 1}), shutdowner.Stats(); got != want {
+		t.Errorf("expected the cumulative counters to be kept at %+v, got %+v", want, got)
+	}
+
+	close(release)
+	<-finished
+	if want, got := (shutdown.Stats{Finished: 1}), shutdowner.SnapshotAndReset(); got != want {
+		t.Errorf("expected delta snapshot %+v, got %+v", want, got)
+	}
+}
+
+func TestShutdowner_SnapshotAndReset_DuringDrain(t *testing.T) {
+	t.Parallel()
+	var logs bytes.Buffer
+	shutdowner := shutdown.NewShutdowner(
+		shutdown.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		shutdown.WithSummaryLog(),
+		shutdown.WithRejectNewRequests(),
+	)
+	const n = 3
+	entered, release := make(chan struct{}, n), make(chan struct{})
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+	for range n {
+		go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ws", nil))
+		<-entered
+	}
+
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/late", nil))
+	// a scrape during the drain
+	if want, got := (shutdown.Stats{Active: n, Started: n, Rejected: 1}), shutdowner.SnapshotAndReset(); got != want {
+		t.Errorf("expected snapshot %+v, got %+v", want, got)
+	}
+	close(release)
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	if r, ok := shutdowner.Report(); !ok || r.Completed != n || r.ActiveAtStart != n {
+		t.Errorf("expected a report with %d completed handlers, got %+v", n, r)
+	}
+	if !strings.Contains(logs.String(), "rejected=1") {
+		t.Errorf("expected the summary to count the rejected request, got %s", logs.String())
+	}
+	if want, got := (shutdown.Stats{Finished: n}), shutdowner.SnapshotAndReset(); got != want {
+		t.Errorf("expected delta snapshot %+v, got %+v", want, got)
+	}
+}
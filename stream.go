@@ -0,0 +1,55 @@
+package shutdown
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// eventStreamBuffer is the buffer size of the subscription backing an EventStream.
+const eventStreamBuffer = 64
+
+// eventJSON is the representation of an Event in an EventStream.
+type eventJSON struct {
+	Type   string    `json:"type"`
+	Time   time.Time `json:"time"`
+	Active int64     `json:"active"`
+	Cause  string    `json:"cause,omitempty"`
+}
+
+// EventStream returns a reader that yields the events of the Shutdowner as newline-delimited JSON objects with the
+// fields "type", "time", "active" and, if any, "cause", e.g. for tools that consume the live drain with jq. Unlike the
+// channels returned by Events, the stream also includes the EventHandlerStarted and EventHandlerFinished events. Like
+// with Events, events are dropped if the reader does not keep up. Closing the reader ends the subscription.
+func (g *Shutdowner) EventStream() io.ReadCloser {
+	events, unsubscribe := g.subscribe(eventStreamBuffer, true)
+	pr, pw := io.Pipe()
+	go func() {
+		enc := json.NewEncoder(pw)
+		for e := range events {
+			var cause string
+			if e.Cause != nil {
+				cause = e.Cause.Error()
+			}
+			if err := enc.Encode(eventJSON{Type: e.Type.String(), Time: e.Time, Active: e.Active, Cause: cause}); err != nil {
+				// the reader has been closed
+				unsubscribe()
+				return
+			}
+		}
+		_ = pw.Close()
+	}()
+	return &eventStream{PipeReader: pr, unsubscribe: unsubscribe}
+}
+
+// eventStream is the reader returned by EventStream.
+type eventStream struct {
+	*io.PipeReader
+	unsubscribe func()
+}
+
+// Close ends the subscription and closes the reader.
+func (s *eventStream) Close() error {
+	s.unsubscribe()
+	return s.PipeReader.Close()
+}
@@ -0,0 +1,80 @@
+package shutdown_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_EventStream(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+	stream := shutdowner.EventStream()
+	defer stream.Close()
+
+	shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	type event struct {
+		Type   string    `json:"type"`
+		Time   time.Time `json:"time"`
+		Active int64     `json:"active"`
+		Cause  string    `json:"cause"`
+	}
+	want := []event{
+		{Type: "handler_started", Active: 1},
+		{Type: "handler_finished", Active: 0},
+		{Type: "shutdown_began", Cause: shutdown.ErrShuttingDown.Error()},
+		{Type: "drain_completed", Cause: shutdown.ErrShuttingDown.Error()},
+	}
+	scanner := bufio.NewScanner(stream)
+	for i, w := range want {
+		if !scanner.Scan() {
+			t.Fatalf("expected event %d, got %v", i, scanner.Err())
+		}
+		var got event
+		if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode %q: %v", scanner.Text(), err)
+		}
+		if got.Type != w.Type || got.Active != w.Active || got.Cause != w.Cause || got.Time.IsZero() {
+			t.Errorf("expected event %d to be %+v, got %+v", i, w, got)
+		}
+	}
+}
+
+// TestShutdowner_EventStream_Close is not run in parallel, since it counts goroutines.
+func TestShutdowner_EventStream_Close(t *testing.T) {
+	var shutdowner shutdown.Shutdowner
+	before := runtime.NumGoroutine()
+
+	for range 10 {
+		stream := shutdowner.EventStream()
+		if err := stream.Close(); err != nil {
+			t.Fatalf("no error expected but got %v", err)
+		}
+	}
+	// emitting after closing must neither block nor panic
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := runtime.NumGoroutine(); got > before {
+		t.Errorf("expected closed streams not to leak goroutines, got %d goroutines instead of %d", got, before)
+	}
+}
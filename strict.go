@@ -0,0 +1,20 @@
+package shutdown
+
+import "log/slog"
+
+// WithStrictMode configures the Shutdowner to panic on misuse that it otherwise tolerates, e.g. wrapping a nil handler
+// with Middleware or calling the release function returned by SubContext more than once, so that such bugs surface
+// early in development and CI. Without it, the misuse is logged at error level and handled leniently.
+func WithStrictMode() Option {
+	return func(g *Shutdowner) {
+		g.cfg.strict = true
+	}
+}
+
+// misuse reports a misuse of the Shutdowner described by msg: it panics in strict mode and logs msg otherwise.
+func (g *Shutdowner) misuse(msg string) {
+	if g.cfg.strict {
+		panic("shutdown: " + msg)
+	}
+	g.log(slog.LevelError, msg)
+}
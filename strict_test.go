@@ -0,0 +1,100 @@
+package shutdown_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestWithStrictMode(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name   string
+		misuse func(g *shutdown.Shutdowner)
+	}{
+		{
+			name: "nil handler",
+			misuse: func(g *shutdown.Shutdowner) {
+				rec := httptest.NewRecorder()
+				g.Middleware(nil).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+				if rec.Code != http.StatusNotFound {
+					panic("unexpected status")
+				}
+			},
+		},
+		{
+			name: "SubContext released twice",
+			misuse: func(g *shutdown.Shutdowner) {
+				_, release := g.SubContext(context.Background())
+				release()
+				release()
+			},
+		},
+		{
+			name: "Track done called twice",
+			misuse: func(g *shutdown.Shutdowner) {
+				done := g.Track()
+				done()
+				done()
+			},
+		},
+		{
+			name: "Barrier left without Enter",
+			misuse: func(g *shutdown.Shutdowner) {
+				b := g.NewBarrier()
+				b.Enter()
+				b.Leave()
+				b.Leave()
+			},
+		},
+		{
+			name: "handler finished without starting",
+			misuse: func(g *shutdown.Shutdowner) {
+				shutdown.Leave(g)
+				if got := g.ActiveCount(); got != 0 {
+					panic("unexpected active count")
+				}
+			},
+		},
+		{
+			name: "negative handler counter",
+			misuse: func(g *shutdown.Shutdowner) {
+				shutdown.Done(g)
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			t.Run("strict", func(t *testing.T) {
+				g := shutdown.NewShutdowner(shutdown.WithStrictMode())
+				defer func() {
+					if r := recover(); r == nil || !strings.HasPrefix(r.(string), "shutdown: ") {
+						t.Errorf("expected a panic describing the misuse, got %v", r)
+					}
+				}()
+				tc.misuse(g)
+			})
+
+			t.Run("lenient", func(t *testing.T) {
+				var logs bytes.Buffer
+				g := shutdown.NewShutdowner(shutdown.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+				tc.misuse(g)
+				if !strings.Contains(logs.String(), "level=ERROR") {
+					t.Errorf("expected the misuse to be logged, got %q", logs.String())
+				}
+				if err := g.Shutdown(context.Background()); err != nil {
+					t.Errorf("no error expected but got %v", err)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package shutdown
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// WithSystemdNotify configures the Shutdowner to report its state to systemd with sd_notify messages over the socket
+// named by the NOTIFY_SOCKET environment variable: "STOPPING=1" along with a status line when shutdown begins, and
+// "READY=1" when NotifyReady is called. Nothing is sent if NOTIFY_SOCKET is unset, e.g. when the service is not run by
+// systemd, and on other operating systems than Linux.
+func WithSystemdNotify() Option {
+	return func(g *Shutdowner) {
+		g.cfg.systemdNotify = true
+	}
+}
+
+// NotifyReady tells systemd that the service is ready if WithSystemdNotify is used, e.g. once the server accepts
+// connections. It is a no-op otherwise.
+func (g *Shutdowner) NotifyReady() error {
+	if !g.cfg.systemdNotify {
+		return nil
+	}
+	return sdNotify("READY=1")
+}
+
+// notifyStopping tells systemd that the service is stopping if WithSystemdNotify is used.
+func (g *Shutdowner) notifyStopping(active int64) {
+	if !g.cfg.systemdNotify {
+		return
+	}
+	if err := sdNotify(fmt.Sprintf("STOPPING=1\nSTATUS=draining %d active handlers", active)); err != nil {
+		g.log(slog.LevelWarn, "failed to notify systemd", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,23 @@
+//go:build linux
+
+package shutdown
+
+import (
+	"net"
+	"os"
+)
+
+// sdNotify sends state to the socket named by NOTIFY_SOCKET, if set.
+func sdNotify(state string) error {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,68 @@
+//go:build linux
+
+package shutdown_test
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+// listenNotifySocket creates a fake systemd notify socket and points NOTIFY_SOCKET to it. Tests using it must not run
+// in parallel, since they modify the environment.
+func listenNotifySocket(t *testing.T) *net.UnixConn {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", name)
+	return conn
+}
+
+func readNotification(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to read notification: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestWithSystemdNotify(t *testing.T) {
+	conn := listenNotifySocket(t)
+	shutdowner := shutdown.NewShutdowner(shutdown.WithSystemdNotify())
+
+	if err := shutdowner.NotifyReady(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if got := readNotification(t, conn); got != "READY=1" {
+		t.Errorf("expected READY=1, got %q", got)
+	}
+
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	if got, want := readNotification(t, conn), "STOPPING=1\nSTATUS=draining 0 active handlers"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestWithSystemdNotify_NoSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	shutdowner := shutdown.NewShutdowner(shutdown.WithSystemdNotify())
+
+	if err := shutdowner.NotifyReady(); err != nil {
+		t.Errorf("expected no error without NOTIFY_SOCKET, got %v", err)
+	}
+	if err := shutdowner.BeginShutdown(); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package shutdown
+
+// sdNotify is a no-op outside of Linux.
+func sdNotify(state string) error {
+	return nil
+}
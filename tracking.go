@@ -0,0 +1,134 @@
+package shutdown
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// WithTracking enables recording the method, URL path and start time of each active handler, which is required for
+// ActivePaths and Snapshot. Tracking costs a map insertion and deletion per request, so it is disabled by default.
+func WithTracking() Option {
+	return func(g *Shutdowner) {
+		g.cfg.tracking = true
+	}
+}
+
+// WithPathSampling configures the tracking of WithTracking to only record the path of a random fraction of the
+// requests, given by rate between 0 and 1, to cap its overhead on servers with many requests. ActivePaths then only
+// returns an approximate, but representative, view of the active handlers. Background tasks started with GoNamed are
+// always recorded.
+func WithPathSampling(rate float64) Option {
+	return func(g *Shutdowner) {
+		g.cfg.sampling = true
+		g.cfg.sampleRate = rate
+	}
+}
+
+// sampled reports whether the path of a request is to be recorded.
+func (g *Shutdowner) sampled() bool {
+	return !g.cfg.sampling || rand.Float64() < g.cfg.sampleRate
+}
+
+// ActiveCount returns the number of handlers wrapped with Middleware that are currently running, along with the running
+// background tasks started with GoNamed, Go or Track and the sub-operations registered with SubContext.
+func (g *Shutdowner) ActiveCount() int64 {
+	return g.active.Load()
+}
+
+// ActiveHandlers is ActiveCount as an int, e.g. for a gauge that is scraped to decide when it is safe to kill a pod. A
+// zero value Shutdowner reports 0.
+func (g *Shutdowner) ActiveHandlers() int {
+	return int(g.active.Load())
+}
+
+// ActivePaths returns the sorted URL paths of the handlers that are currently running, along with the names of the
+// running background tasks started with GoNamed. It returns nil if tracking is not enabled with WithTracking. With
+// WithPathSampling, only the sampled handlers are included.
+func (g *Shutdowner) ActivePaths() []string {
+	if !g.cfg.tracking {
+		return nil
+	}
+	g.trackMu.Lock()
+	paths := make([]string, 0, len(g.handlers))
+	for _, info := range g.handlers {
+		paths = append(paths, info.Path)
+	}
+	g.trackMu.Unlock()
+	sort.Strings(paths)
+	return paths
+}
+
+// HandlerInfo describes an active handler in a Snapshot.
+type HandlerInfo struct {
+	// Method is the method of the request, or empty for a background task started with GoNamed.
+	Method string `json:"method,omitempty"`
+	// Path is the URL path of the request, or the name of the background task.
+	Path string `json:"path"`
+	// Started is the time the handler was invoked.
+	Started time.Time `json:"started"`
+}
+
+// Snapshot returns the handlers that are currently running, along with the running background tasks started with
+// GoNamed, ordered by the time they started. It returns nil if tracking is not enabled with WithTracking. With
+// WithPathSampling, only the sampled handlers are included.
+func (g *Shutdowner) Snapshot() []HandlerInfo {
+	if !g.cfg.tracking {
+		return nil
+	}
+	g.trackMu.Lock()
+	infos := make([]HandlerInfo, 0, len(g.handlers))
+	for _, info := range g.handlers {
+		infos = append(infos, info)
+	}
+	g.trackMu.Unlock()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Started.Before(infos[j].Started) })
+	return infos
+}
+
+// SnapshotHandler returns a handler that responds with the Snapshot as a JSON array, e.g. to be mounted at
+// "/debug/drain" for debugging a drain that does not complete.
+//
+// The handler is never tracked by Middleware, see WithSkipPaths for handlers that are wrapped as part of a larger
+// handler like a http.ServeMux.
+func (g *Shutdowner) SnapshotHandler() http.Handler {
+	return snapshotHandler{g: g}
+}
+
+type snapshotHandler struct {
+	g *Shutdowner
+}
+
+func (snapshotHandler) untracked() {}
+
+func (h snapshotHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	infos := h.g.Snapshot()
+	if infos == nil {
+		infos = []HandlerInfo{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(infos)
+}
+
+// track records a handler or background task under path, e.g. the URL path of a request, and returns the id to pass to
+// untrack once it has returned. method is empty for background tasks.
+func (g *Shutdowner) track(method, path string) uint64 {
+	id := g.nextID.Add(1)
+	info := HandlerInfo{Method: method, Path: path, Started: time.Now()}
+	g.trackMu.Lock()
+	if g.handlers == nil {
+		g.handlers = make(map[uint64]HandlerInfo)
+	}
+	g.handlers[id] = info
+	g.trackMu.Unlock()
+	return id
+}
+
+// untrack removes the handler with the given id from the active handlers.
+func (g *Shutdowner) untrack(id uint64) {
+	g.trackMu.Lock()
+	delete(g.handlers, id)
+	g.trackMu.Unlock()
+}
@@ -0,0 +1,141 @@
+package shutdown_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_ActivePaths(t *testing.T) {
+	t.Parallel()
+
+	for _, tracking := range []bool{false, true} {
+		var opts []shutdown.Option
+		if tracking {
+			opts = append(opts, shutdown.WithTracking())
+		}
+		shutdowner := shutdown.NewShutdowner(opts...)
+
+		release := make(chan struct{})
+		finished := make(chan struct{})
+		for _, path := range []string{"/b", "/a"} {
+			started := make(chan struct{})
+			go func() {
+				shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					close(started)
+					<-release
+				})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+				finished <- struct{}{}
+			}()
+			<-started
+		}
+
+		if got := shutdowner.ActiveCount(); got != 2 {
+			t.Errorf("expected 2 active handlers, got %d", got)
+		}
+		var want []string
+		if tracking {
+			want = []string{"/a", "/b"}
+		}
+		if got := shutdowner.ActivePaths(); !slices.Equal(got, want) {
+			t.Errorf("tracking %v: expected active paths %v, got %v", tracking, want, got)
+		}
+
+		close(release)
+		<-finished
+		<-finished
+		if got := shutdowner.ActiveCount(); got != 0 {
+			t.Errorf("expected no active handlers, got %d", got)
+		}
+		if got := shutdowner.ActivePaths(); len(got) != 0 {
+			t.Errorf("expected no active paths, got %v", got)
+		}
+	}
+}
+
+func TestWithPathSampling(t *testing.T) {
+	t.Parallel()
+	const requests, rate = 2000, 0.25
+	shutdowner := shutdown.NewShutdowner(shutdown.WithTracking(), shutdown.WithPathSampling(rate))
+
+	var recorded int
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorded += len(shutdowner.ActivePaths())
+	}))
+	for range requests {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	// the bounds are more than 5 standard deviations away from the expected 500
+	if recorded < 400 || recorded > 600 {
+		t.Errorf("expected about %v of %d requests to be recorded, got %d", rate, requests, recorded)
+	}
+	if got := shutdowner.ActiveCount(); got != 0 {
+		t.Errorf("expected sampling not to affect the active count, got %d", got)
+	}
+}
+
+func TestShutdowner_Snapshot(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithTracking())
+	if got := shutdown.NewShutdowner().Snapshot(); got != nil {
+		t.Errorf("expected no snapshot without tracking, got %v", got)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	entered := make(chan struct{})
+	handler := shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+	before := time.Now()
+	for _, r := range []*http.Request{httptest.NewRequest("GET", "/ws", nil), httptest.NewRequest("POST", "/upload", nil)} {
+		go handler.ServeHTTP(httptest.NewRecorder(), r)
+		<-entered
+	}
+
+	snapshot := shutdowner.Snapshot()
+	if len(snapshot) != 2 {
+		t.Fatalf("expected 2 active handlers, got %v", snapshot)
+	}
+	for i, want := range []shutdown.HandlerInfo{{Method: "GET", Path: "/ws"}, {Method: "POST", Path: "/upload"}} {
+		if got := snapshot[i]; got.Method != want.Method || got.Path != want.Path || got.Started.Before(before) {
+			t.Errorf("expected handler %d to be %s %s started after %v, got %+v", i, want.Method, want.Path, before, got)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	shutdowner.Middleware(shutdowner.SnapshotHandler()).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/drain", nil))
+	var rendered []shutdown.HandlerInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &rendered); err != nil {
+		t.Fatalf("expected a JSON body, got %q: %v", rec.Body.String(), err)
+	}
+	if len(rendered) != 2 || rendered[0].Path != "/ws" || rendered[1].Path != "/upload" {
+		t.Errorf("expected the snapshot to be rendered without the debug request itself, got %+v", rendered)
+	}
+}
+
+func TestShutdowner_ActiveHandlers(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+	if got := shutdowner.ActiveHandlers(); got != 0 {
+		t.Errorf("expected the zero value to report 0, got %d", got)
+	}
+
+	var during int
+	shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		during = shutdowner.ActiveHandlers()
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if during != 1 {
+		t.Errorf("expected 1 active handler while serving, got %d", during)
+	}
+	if got := shutdowner.ActiveHandlers(); got != 0 {
+		t.Errorf("expected 0 active handlers after serving, got %d", got)
+	}
+}
@@ -0,0 +1,85 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrMiddlewareMissing is returned by Validate if the synthetic request did not pass the Shutdowner's Middleware.
+	ErrMiddlewareMissing = errors.New("shutdown: middleware not found in handler chain")
+	// ErrMiddlewareNotOutermost is returned by Validate if the synthetic request passed the Shutdowner's Middleware,
+	// but was modified by another handler before.
+	ErrMiddlewareNotOutermost = errors.New("shutdown: middleware is not the outermost handler")
+)
+
+// validationKey is the context key under which Validate stores its probe in the synthetic request.
+type validationKey struct{}
+
+// validationProbe records what the Shutdowner's Middleware observed of the synthetic request sent by Validate.
+type validationProbe struct {
+	g       *Shutdowner
+	reached bool
+	ctx     context.Context
+	w       http.ResponseWriter
+}
+
+// Validate sends a synthetic GET request for "/" through handler and verifies that it reaches the Shutdowner's
+// Middleware without having been modified by another handler first, i.e. that the middleware is present and outermost.
+// The middleware answers the synthetic request itself, so handlers behind it are not invoked. It is meant to be called
+// once at startup to catch a misconfigured handler chain early.
+//
+// Note that if the middleware is missing or not outermost, the synthetic request is served by the application handlers
+// like any other request. They receive an http.ResponseWriter that discards the response and implements neither
+// http.Hijacker nor http.Flusher, so handlers that upgrade connections, e.g. to websockets, fail or may even panic.
+// Validate the chain in front of such handlers, or route "/" to a handler that tolerates it.
+func (g *Shutdowner) Validate(handler http.Handler) error {
+	g.validating.Add(1)
+	defer g.validating.Add(-1)
+
+	probe := &validationProbe{g: g}
+	ctx := context.WithValue(context.Background(), validationKey{}, probe)
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	if err != nil {
+		return err
+	}
+	w := &probeWriter{header: make(http.Header)}
+
+	handler.ServeHTTP(w, r)
+
+	switch {
+	case !probe.reached:
+		return ErrMiddlewareMissing
+	case probe.ctx != ctx || probe.w != http.ResponseWriter(w):
+		return ErrMiddlewareNotOutermost
+	}
+	return nil
+}
+
+// validate reports whether r is the synthetic request sent by Validate for g and records the observation if so. The
+// request context is only inspected while Validate runs, so the check is cheap for regular requests.
+func (g *Shutdowner) validate(w http.ResponseWriter, r *http.Request) bool {
+	if g.validating.Load() == 0 {
+		return false
+	}
+	probe, ok := r.Context().Value(validationKey{}).(*validationProbe)
+	if !ok || probe.g != g {
+		return false
+	}
+	probe.reached = true
+	probe.ctx = r.Context()
+	probe.w = w
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// probeWriter is the http.ResponseWriter passed along with the synthetic request sent by Validate. It discards the
+// response.
+type probeWriter struct {
+	header http.Header
+}
+
+func (w *probeWriter) Header() http.Header         { return w.header }
+func (w *probeWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *probeWriter) WriteHeader(int)             {}
@@ -0,0 +1,82 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestShutdowner_Validate(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+	// other modifies the request context, so it is detected when wrapping shutdowner's middleware
+	other := shutdown.NewShutdowner(shutdown.WithContextInjection())
+
+	var served bool
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	})
+	type ctxKey struct{}
+	withContext := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, true)))
+		})
+	}
+	// passThrough does not modify the request, so it is indistinguishable from the middleware being outermost
+	passThrough := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	tt := []struct {
+		name        string
+		handler     http.Handler
+		expectedErr error
+	}{
+		{
+			name:    "middleware outermost",
+			handler: shutdowner.Middleware(withContext(app)),
+		},
+		{
+			name:    "middleware behind pass-through handler",
+			handler: passThrough(shutdowner.Middleware(app)),
+		},
+		{
+			name:        "middleware missing",
+			handler:     withContext(app),
+			expectedErr: shutdown.ErrMiddlewareMissing,
+		},
+		{
+			name:        "middleware of another shutdowner",
+			handler:     other.Middleware(app),
+			expectedErr: shutdown.ErrMiddlewareMissing,
+		},
+		{
+			name:        "middleware behind handler modifying the request",
+			handler:     withContext(shutdowner.Middleware(app)),
+			expectedErr: shutdown.ErrMiddlewareNotOutermost,
+		},
+		{
+			name:        "middleware behind another shutdowner",
+			handler:     other.Middleware(shutdowner.Middleware(app)),
+			expectedErr: shutdown.ErrMiddlewareNotOutermost,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			served = false
+			err := shutdowner.Validate(tc.handler)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("expected %v, but got %v", tc.expectedErr, err)
+			}
+			if tc.expectedErr == nil && served {
+				t.Errorf("expected the application handler not to be invoked")
+			}
+		})
+	}
+}
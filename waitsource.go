@@ -0,0 +1,82 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+)
+
+// WaitSource is in-flight work tracked outside of the Shutdowner, e.g. a job queue with its own sync.WaitGroup, that
+// Shutdown should wait for in addition to the handlers.
+type WaitSource interface {
+	// Wait blocks until all work of the source has finished, or until ctx is done, in which case it returns the
+	// context error.
+	Wait(ctx context.Context) error
+}
+
+// WaitSourceFunc adapts a function to the WaitSource interface.
+type WaitSourceFunc func(ctx context.Context) error
+
+// Wait calls f(ctx).
+func (f WaitSourceFunc) Wait(ctx context.Context) error {
+	return f(ctx)
+}
+
+// AddWaitSource registers a source of in-flight work that Shutdown waits for after all handlers have returned. Since
+// the source keeps track of its work itself, it is not counted as active handlers. Errors returned by the source are
+// returned by Shutdown.
+func (g *Shutdowner) AddWaitSource(source WaitSource) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sources = append(g.sources, source)
+}
+
+// waitSources waits for each of the sources and returns their errors joined.
+func waitSources(ctx context.Context, sources []WaitSource) error {
+	var errs []error
+	for _, s := range sources {
+		if err := s.Wait(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// awaitSources waits for the sources like waitSources, but returns as soon as the drain is abandoned or ctx is done,
+// even if a source does not respect ctx.
+func (g *Shutdowner) awaitSources(ctx context.Context, abandoned <-chan struct{}, sources []WaitSource) error {
+	if len(sources) == 0 {
+		return nil
+	}
+	d := make(chan error, 1)
+	go func() { d <- waitSources(ctx, sources) }()
+	select {
+	case err := <-d:
+		return err
+	case <-abandoned:
+		return ErrAborted
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Merge makes the shutdown of g include other, e.g. when an application replaces its Shutdowner with a new instance
+// while the old one still tracks in-flight requests. When the shutdown of g begins, the shutdown of other begins as
+// well, with the same cause, unless its guard vetoes it, in which case the guard's error is reported in the HookErr
+// field of the Report. Once all handlers of g have returned, Shutdown of g calls Shutdown of other with the same
+// context, which waits for the handlers, hooks and sources of other to finish. Errors of other's drain are returned by
+// Shutdown of g. The counters of g, e.g. ActiveCount, do not include the handlers of other.
+//
+// Merge panics if other is g. A cycle of merged Shutdowners, e.g. a merged into b and b merged into a, deadlocks the
+// drain, so it must be avoided.
+func (g *Shutdowner) Merge(other *Shutdowner) {
+	if other == g {
+		panic("shutdown: Shutdowner merged into itself")
+	}
+	g.OnShutdownErr(func(ctx context.Context) error {
+		g.mu.Lock()
+		cause := g.cause
+		g.mu.Unlock()
+		return other.begin(ctx, cause, true)
+	})
+	g.AddWaitSource(WaitSourceFunc(other.Shutdown))
+}
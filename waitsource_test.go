@@ -0,0 +1,118 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+// jobQueue is a fake subsystem tracking its in-flight jobs with its own WaitGroup.
+type jobQueue struct {
+	wg sync.WaitGroup
+}
+
+func (q *jobQueue) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestShutdowner_AddWaitSource(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+	var queue jobQueue
+	shutdowner.AddWaitSource(&queue)
+
+	release := make(chan struct{})
+	queue.wg.Add(1)
+	go func() {
+		defer queue.wg.Done()
+		<-release
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v while the job is running, but got %v", context.DeadlineExceeded, err)
+	}
+	if got := shutdowner.ActiveCount(); got != 0 {
+		t.Errorf("expected the job not to be counted as active handler, got %d", got)
+	}
+
+	close(release)
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+}
+
+func TestShutdowner_AddWaitSource_Error(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+	errSource := errors.New("queue broken")
+	shutdowner.AddWaitSource(shutdown.WaitSourceFunc(func(ctx context.Context) error { return errSource }))
+
+	if err := shutdowner.Shutdown(context.Background()); !errors.Is(err, errSource) {
+		t.Errorf("expected %v, but got %v", errSource, err)
+	}
+}
+
+func TestShutdowner_Merge(t *testing.T) {
+	t.Parallel()
+	var old, replacement shutdown.Shutdowner
+	replacement.Merge(&old)
+
+	release := make(chan struct{})
+	entered := make(chan struct{}, 2)
+	for _, g := range []*shutdown.Shutdowner{&old, &replacement} {
+		go g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entered <- struct{}{}
+			<-release
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	<-entered
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := replacement.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v while both handlers are running, but got %v", context.DeadlineExceeded, err)
+	}
+	if !old.IsShuttingDown() {
+		t.Errorf("expected the drain to begin the shutdown of the merged instance")
+	}
+
+	close(release)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := replacement.Shutdown(ctx); err != nil {
+		t.Errorf("no error expected but got %v", err)
+	}
+	if !old.IsDrained() || !replacement.IsDrained() {
+		t.Errorf("expected both instances to be drained")
+	}
+}
+
+func TestShutdowner_Merge_Self(t *testing.T) {
+	t.Parallel()
+	var shutdowner shutdown.Shutdowner
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected merging a Shutdowner into itself to panic")
+		}
+	}()
+	shutdowner.Merge(&shutdowner)
+}
@@ -0,0 +1,101 @@
+package shutdown
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	webhookShuttingDown = "shutting_down"
+	webhookDrained      = "drained"
+	webhookTimeout      = "timeout"
+
+	webhookAttempts       = 3
+	webhookBackoff        = 100 * time.Millisecond
+	webhookAttemptTimeout = 5 * time.Second
+)
+
+// webhookStates maps the webhook states to the bits recording in Shutdowner.webhookSent that they have been sent.
+var webhookStates = map[string]uint32{
+	webhookShuttingDown: 1 << 0,
+	webhookDrained:      1 << 1,
+	webhookTimeout:      1 << 2,
+}
+
+// webhookPayload is the JSON document posted to the webhook configured with WithShutdownWebhook.
+type webhookPayload struct {
+	State      string `json:"state"`
+	Active     int64  `json:"active"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// WithShutdownWebhook configures the Shutdowner to POST a small JSON payload with the state, the number of active
+// handlers and the time elapsed since shutdown began to url when shutdown begins, when the drain completes and when it
+// times out. Each state is posted at most once, even if Shutdown is called several times. Delivery is best-effort:
+// failed requests are retried a couple of times with exponential backoff in the background and never delay the drain.
+// Each attempt is bounded by a timeout of five seconds. If client is nil, http.DefaultClient is used.
+func WithShutdownWebhook(url string, client *http.Client) Option {
+	return func(g *Shutdowner) {
+		if client == nil {
+			client = http.DefaultClient
+		}
+		g.cfg.webhookURL = url
+		g.cfg.webhookClient = client
+	}
+}
+
+// notifyWebhook posts the given state to the configured webhook in the background, if any and if the state has not been
+// posted before.
+func (g *Shutdowner) notifyWebhook(state string) {
+	if g.cfg.webhookURL == "" {
+		return
+	}
+	bit := webhookStates[state]
+	if g.webhookSent.Or(bit)&bit != 0 {
+		return
+	}
+
+	g.mu.Lock()
+	startedAt := g.startedAt
+	g.mu.Unlock()
+
+	body, err := json.Marshal(webhookPayload{
+		State:      state,
+		Active:     g.active.Load(),
+		DurationMS: time.Since(startedAt).Milliseconds(),
+	})
+	if err != nil {
+		return
+	}
+
+	go func() {
+		backoff := webhookBackoff
+		for attempt := 1; ; attempt++ {
+			if g.postWebhook(body) || attempt == webhookAttempts {
+				return
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}()
+}
+
+// postWebhook sends a single webhook request and reports whether it was accepted.
+func (g *Shutdowner) postWebhook(body []byte) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookAttemptTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.cfg.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := g.cfg.webhookClient.Do(req)
+	if err != nil {
+		return false
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode < 300
+}
@@ -0,0 +1,101 @@
+package shutdown_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestWithShutdownWebhook(t *testing.T) {
+	t.Parallel()
+
+	type payload struct {
+		State      string `json:"state"`
+		Active     int64  `json:"active"`
+		DurationMS int64  `json:"duration_ms"`
+	}
+
+	var (
+		mu       sync.Mutex
+		calls    int
+		received = make(chan payload, 10)
+	)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		first := calls == 1
+		mu.Unlock()
+		if first {
+			// fail the first delivery to exercise the retry
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var p payload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("failed to decode webhook payload: %v", err)
+		}
+		received <- p
+	}))
+	defer webhook.Close()
+
+	shutdowner := shutdown.NewShutdowner(shutdown.WithShutdownWebhook(webhook.URL, webhook.Client()))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, but got %v", context.DeadlineExceeded, err)
+	}
+
+	close(release)
+	<-finished
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+
+	var states []string
+	for range 3 {
+		select {
+		case p := <-received:
+			states = append(states, p.State)
+			if p.State != "drained" && p.Active != 1 {
+				t.Errorf("expected 1 active handler for state %q, got %d", p.State, p.Active)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for webhook calls, got %v", states)
+		}
+	}
+	// states are only posted once per drain
+	if err := shutdowner.Shutdown(context.Background()); err != nil {
+		t.Fatalf("no error expected but got %v", err)
+	}
+	select {
+	case p := <-received:
+		t.Errorf("expected no further webhook calls, got state %q", p.State)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	sort.Strings(states)
+	if want := []string{"drained", "shutting_down", "timeout"}; !slices.Equal(states, want) {
+		t.Errorf("expected states %v, got %v", want, states)
+	}
+}
@@ -0,0 +1,108 @@
+package shutdown
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// WithHijackTracking configures the middleware to wrap the http.ResponseWriter of the handlers so that connections
+// obtained by hijacking are tracked with TrackConn automatically, including the ones hijacked through
+// http.ResponseController.
+func WithHijackTracking() Option {
+	return func(g *Shutdowner) {
+		g.cfg.hijackTracking = true
+	}
+}
+
+// WithCountFromFirstWrite configures the middleware to count a handler as active only once it starts to respond, i.e.
+// at its first call of Write, WriteHeader, Flush or Hijack, rather than as soon as it is invoked. Handlers that return
+// without responding anything are not counted at all, and Shutdown does not wait for handlers that have not started to
+// respond yet.
+func WithCountFromFirstWrite() Option {
+	return func(g *Shutdowner) {
+		g.cfg.countFromFirstWrite = true
+	}
+}
+
+// responseWriter is the http.ResponseWriter passed to the handlers when the middleware needs to observe the response.
+// It implements Unwrap, so http.ResponseController still reaches the capabilities of the underlying writer, like
+// SetReadDeadline and SetWriteDeadline.
+type responseWriter struct {
+	http.ResponseWriter
+	g        *Shutdowner
+	cc       *counters
+	path     string
+	status   int
+	hijacked bool
+	counted  bool
+}
+
+// start counts the handler as active with WithCountFromFirstWrite, once it starts to respond.
+func (w *responseWriter) start() {
+	if w.g.cfg.countFromFirstWrite && !w.counted {
+		w.counted = true
+		w.g.enter(w.cc)
+	}
+}
+
+// writingHeader is called before the response header is written.
+func (w *responseWriter) writingHeader() {
+	if w.g.cfg.connectionCloseOnDrain && w.g.shuttingDown.Load() {
+		w.Header().Set("Connection", "close")
+	}
+}
+
+// leave counts the handler as finished if start counted it as active.
+func (w *responseWriter) leave() {
+	if w.counted {
+		w.g.leave(w.cc)
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.start()
+	if w.status == 0 {
+		w.writingHeader()
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.start()
+	if w.status == 0 {
+		w.writingHeader()
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher. It is a no-op if the underlying writer does not support flushing.
+func (w *responseWriter) Flush() {
+	w.start()
+	if w.status == 0 {
+		w.writingHeader()
+		w.status = http.StatusOK
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker. The returned connection is tracked with TrackConn if WithHijackTracking is used.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.start()
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.hijacked = true
+	if w.g.cfg.hijackTracking {
+		conn = w.g.trackConn(conn, w.path)
+	}
+	return conn, rw, nil
+}
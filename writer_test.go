@@ -0,0 +1,126 @@
+package shutdown_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	shutdown "github.com/mheck136/ws-shutdown"
+)
+
+func TestWithHijackTracking_ResponseController(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithHijackTracking())
+
+	errs := make(chan error, 3)
+	server := httptest.NewServer(shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		// reaches the underlying writer through Unwrap
+		errs <- rc.SetWriteDeadline(time.Now().Add(time.Second))
+		errs <- rc.SetReadDeadline(time.Now().Add(time.Second))
+
+		conn, rw, err := rc.Hijack()
+		errs <- err
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nhi")
+		_ = rw.Flush()
+	})))
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := bufio.NewReader(resp.Body).ReadString('\n')
+	if body != "hi" {
+		t.Errorf("expected body %q from the hijacked connection, got %q", "hi", body)
+	}
+	for _, name := range []string{"SetWriteDeadline", "SetReadDeadline", "Hijack"} {
+		if err := <-errs; err != nil {
+			t.Errorf("%s: no error expected but got %v", name, err)
+		}
+	}
+}
+
+func TestWithHijackTracking_Flusher(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithHijackTracking())
+
+	rec := httptest.NewRecorder()
+	shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("x"))
+		w.(http.Flusher).Flush()
+		if _, _, err := http.NewResponseController(w).Hijack(); err == nil {
+			t.Errorf("expected hijacking a recorder to fail")
+		}
+	})).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if !rec.Flushed {
+		t.Errorf("expected the flush to reach the underlying writer")
+	}
+}
+
+func TestWithCountFromFirstWrite(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name          string
+		opts          []shutdown.Option
+		wantSilent    int64
+		wantResponded int64
+	}{
+		{name: "default", wantSilent: 1, wantResponded: 1},
+		{name: "from first write", opts: []shutdown.Option{shutdown.WithCountFromFirstWrite()}, wantSilent: 0, wantResponded: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			silent := shutdown.NewShutdowner(tc.opts...)
+			silent.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+				ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			if got := silent.Stats().Started; got != tc.wantSilent {
+				t.Errorf("expected %d started handlers for a silent handler, got %d", tc.wantSilent, got)
+			}
+
+			responded := shutdown.NewShutdowner(tc.opts...)
+			responded.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+				if got := responded.ActiveCount(); got != 1 {
+					t.Errorf("expected 1 active handler after writing the header, got %d", got)
+				}
+			})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			if got := responded.Stats(); got.Started != tc.wantResponded || got.Finished != tc.wantResponded || got.Active != 0 {
+				t.Errorf("unexpected stats for a responding handler: %+v", got)
+			}
+		})
+	}
+}
+
+func TestWithCountFromFirstWrite_ShutdownIgnoresHandlersBeforeFirstWrite(t *testing.T) {
+	t.Parallel()
+	shutdowner := shutdown.NewShutdowner(shutdown.WithCountFromFirstWrite())
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		shutdowner.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(entered)
+			<-release
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdowner.Shutdown(ctx); err != nil {
+		t.Errorf("expected the drain to ignore a handler that has not written yet, got %v", err)
+	}
+	close(release)
+	<-done
+}